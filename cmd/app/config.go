@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+
+	"k8s.io/klog/v2"
+
+	"github.com/k-cloud-labs/kinitiras/cmd/app/options"
+	"github.com/k-cloud-labs/kinitiras/pkg/allowlist"
+	"github.com/k-cloud-labs/kinitiras/pkg/util/filewatcher"
+)
+
+// watchConfigFile reloads the configuration file whenever it changes. It blocks until ctx is done.
+func (s *setupManager) watchConfigFile(ctx context.Context) {
+	if err := filewatcher.Watch(ctx, s.opts.ConfigFile, s.reloadConfig); err != nil {
+		klog.ErrorS(err, "failed to watch config file, hot reload disabled.", "path", s.opts.ConfigFile)
+	}
+}
+
+// reloadConfig applies log verbosity, pre-cache resources and allowlist from the configuration file.
+// Other settings only take effect after restart.
+func (s *setupManager) reloadConfig() {
+	cfg, err := options.LoadConfigFile(s.opts.ConfigFile)
+	if err != nil {
+		klog.ErrorS(err, "failed to reload config file, keep current config.")
+		return
+	}
+
+	if errs := options.ValidateConfig(cfg, nil); len(errs) != 0 {
+		klog.ErrorS(errs.ToAggregate(), "invalid config file, keep current config.", "path", s.opts.ConfigFile)
+		return
+	}
+
+	if options.StaticConfigChanged(s.opts.Config, cfg) {
+		klog.InfoS("server, cert or data source settings changed, restart to take effect.", "path", s.opts.ConfigFile)
+	}
+
+	s.optsMu.Lock()
+	defer s.optsMu.Unlock()
+	if err := s.opts.ApplyReloadableConfig(cfg); err != nil {
+		klog.ErrorS(err, "failed to apply config file.", "path", s.opts.ConfigFile)
+		return
+	}
+
+	if err := s.opts.PreCacheResources.Resolve(s.restMapper); err != nil {
+		klog.ErrorS(err, "failed to resolve pre-cache resources.", "path", s.opts.ConfigFile)
+	}
+	s.preCacheTracker.SetPinned(s.opts.PreCacheResourcesToGVKList())
+	s.allowlist.Update(s.opts.Allowlist)
+	if s.webhookConfigController != nil {
+		s.webhookConfigController.SetNamespaceSelector(allowlist.WebhookNamespaceSelector(s.opts.Allowlist))
+	}
+
+	klog.InfoS("config file reloaded.", "path", s.opts.ConfigFile)
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/controller/cert"
+)
+
+// readyzChecks returns the checks of /readyz, each of them is served as a sub-check, e.g. /readyz/informers.
+func (s *setupManager) readyzChecks(readiness *shutdownReadiness) map[string]healthz.Checker {
+	return map[string]healthz.Checker{
+		"shutdown":    readiness.Check,
+		"informers":   s.informersSynced,
+		"precache":    s.preCacheSynced,
+		"certs":       cert.Checker(s.opts.CertDir),
+		"interrupter": s.interrupterStarted,
+	}
+}
+
+// informersSynced fails if the informer of any policy or namespaces has not synced.
+func (s *setupManager) informersSynced(_ *http.Request) error {
+	for _, gvr := range []schema.GroupVersionResource{opGVR, copGVR, cvpGVR, nsGVR} {
+		if !s.informerManager.Informer(gvr).HasSynced() {
+			return fmt.Errorf("informer of %s has not synced", gvr.GroupResource())
+		}
+	}
+	return nil
+}
+
+// preCacheSynced fails until the cache of pre-cached resources has synced. Resources referred by policies are
+// registered in the background, they hold readiness until their cache has synced as well.
+// The dynamic lister exposes no informers, so the sync is tracked by its registration instead of listing resources.
+func (s *setupManager) preCacheSynced(_ *http.Request) error {
+	if atomic.LoadInt32(&s.preCacheReady) == 0 {
+		return fmt.Errorf("pre-cached resources have not synced")
+	}
+	if pending := s.preCacheTracker.Pending(); len(pending) != 0 {
+		return fmt.Errorf("pre-cached resources %v have not synced", pending)
+	}
+	return nil
+}
+
+// interrupterStarted fails until the policy interrupters have started up.
+func (s *setupManager) interrupterStarted(_ *http.Request) error {
+	if atomic.LoadInt32(&s.interrupterReady) == 0 {
+		return fmt.Errorf("policy interrupters have not started")
+	}
+	return nil
+}
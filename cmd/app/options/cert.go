@@ -0,0 +1,105 @@
+package options
+
+import (
+	"os"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	configv1alpha1 "github.com/k-cloud-labs/kinitiras/pkg/apis/config/v1alpha1"
+	"github.com/k-cloud-labs/kinitiras/pkg/controller/cert"
+)
+
+// Environment variables of the cert rotator, they are the fallback of cert flags not set explicitly and missing from
+// the cert section of the configuration file, so the file given to a deployment is not overridden by its environment.
+const (
+	EnvNamespace        = "NAMESPACE"
+	EnvSecretName       = "SECRET"
+	EnvCAOrganization   = "CA_ORGANIZATION"
+	EnvCAName           = "CA_NAME"
+	EnvServiceName      = "SERVICE_NAME"
+	EnvMutatingConfig   = "MUTATING_CONFIG"
+	EnvValidatingConfig = "VALIDATING_CONFIG"
+)
+
+// applyCertConfig overrides cert options whose flags were not set explicitly with values from cfg, or environment
+// variables if the values are empty.
+func (o *Options) applyCertConfig(cfg configv1alpha1.CertConfiguration) {
+	for _, item := range []struct {
+		flag  string
+		env   string
+		value string
+		field *string
+	}{
+		{flag: "cert-namespace", env: EnvNamespace, value: cfg.Namespace, field: &o.CertNamespace},
+		{flag: "cert-secret-name", env: EnvSecretName, value: cfg.SecretName, field: &o.CertSecretName},
+		{flag: "cert-ca-name", env: EnvCAName, value: cfg.CAName, field: &o.CertCAName},
+		{flag: "cert-ca-organization", env: EnvCAOrganization, value: cfg.CAOrganization, field: &o.CertCAOrganization},
+		{flag: "cert-service-name", env: EnvServiceName, value: cfg.ServiceName, field: &o.CertServiceName},
+		{flag: "cert-mutating-config", env: EnvMutatingConfig, value: cfg.MutatingConfig, field: &o.CertMutatingConfig},
+		{flag: "cert-validating-config", env: EnvValidatingConfig, value: cfg.ValidatingConfig, field: &o.CertValidatingConfig},
+	} {
+		if o.flagChanged(item.flag) {
+			continue
+		}
+		if item.value != "" {
+			*item.field = item.value
+			continue
+		}
+		if v := os.Getenv(item.env); v != "" {
+			*item.field = v
+		}
+	}
+
+	if len(cfg.ExtraDNSNames) != 0 && !o.flagChanged("cert-extra-dns-names") {
+		o.CertExtraDNSNames = cfg.ExtraDNSNames
+	}
+	if cfg.Validity != nil && !o.flagChanged("cert-validity") {
+		o.CertValidity = cfg.Validity.Duration
+	}
+	if cfg.Lookahead != nil && !o.flagChanged("cert-lookahead") {
+		o.CertLookahead = cfg.Lookahead.Duration
+	}
+	if len(cfg.CABundleTargets) != 0 && !o.flagChanged("cert-ca-bundle-targets") {
+		o.CertCABundleTargets = cfg.CABundleTargets
+	}
+	if cfg.CABundleSelector != "" && !o.flagChanged("cert-ca-bundle-selector") {
+		o.CertCABundleSelector = cfg.CABundleSelector
+	}
+}
+
+// CertOptions returns the defaulted options of the cert rotator. Invalid CertCABundleTargets and CertCABundleSelector
+// are left out, they are reported by Validate.
+func (o *Options) CertOptions() cert.Options {
+	certOpts := cert.Options{
+		Namespace:      o.CertNamespace,
+		SecretName:     o.CertSecretName,
+		CAOrganization: o.CertCAOrganization,
+		CAName:         o.CertCAName,
+		ServiceName:    o.CertServiceName,
+		CertDir:        o.CertDir,
+		ExtraDNSNames:  o.CertExtraDNSNames,
+		Validity:       o.CertValidity,
+		Lookahead:      o.CertLookahead,
+		Webhooks: []cert.WebhookInfo{
+			{
+				Name: o.CertMutatingConfig,
+				Type: cert.Mutating,
+			},
+			{
+				Name: o.CertValidatingConfig,
+				Type: cert.Validating,
+			},
+		},
+	}
+	for _, target := range o.CertCABundleTargets {
+		if webhook, err := cert.ParseWebhookInfo(target); err == nil {
+			certOpts.Webhooks = append(certOpts.Webhooks, webhook)
+		}
+	}
+	if selector, err := labels.Parse(o.CertCABundleSelector); err == nil {
+		certOpts.WebhookSelector = selector
+	}
+	certOpts.Default()
+
+	return certOpts
+}
@@ -0,0 +1,130 @@
+package options
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+
+	"sigs.k8s.io/yaml"
+
+	configv1alpha1 "github.com/k-cloud-labs/kinitiras/pkg/apis/config/v1alpha1"
+)
+
+// LoadConfigFile reads and decodes the configuration file located at path.
+func LoadConfigFile(path string) (*configv1alpha1.WebhookConfiguration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %q: %w", path, err)
+	}
+
+	cfg := &configv1alpha1.WebhookConfiguration{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode config file %q: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Complete loads the configuration file if one was given and fills options not set by flags, from the file and
+// environment variables of the cert rotator.
+func (o *Options) Complete() error {
+	if o.ConfigFile == "" {
+		o.applyCertConfig(configv1alpha1.CertConfiguration{})
+		return nil
+	}
+
+	cfg, err := LoadConfigFile(o.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	return o.ApplyConfig(cfg)
+}
+
+// ApplyConfig overrides options whose flags were not set explicitly with values from cfg.
+func (o *Options) ApplyConfig(cfg *configv1alpha1.WebhookConfiguration) error {
+	o.Config = cfg
+
+	if cfg.Server.BindAddress != "" && !o.flagChanged("bind-address") {
+		o.BindAddress = cfg.Server.BindAddress
+	}
+	if cfg.Server.SecurePort != 0 && !o.flagChanged("secure-port") {
+		o.SecurePort = cfg.Server.SecurePort
+	}
+	if cfg.Server.MetricsBindAddress != "" && !o.flagChanged("metrics-bind-address") {
+		o.MetricsBindAddress = cfg.Server.MetricsBindAddress
+	}
+	if cfg.Server.CertDir != "" && !o.flagChanged("cert-dir") {
+		o.CertDir = cfg.Server.CertDir
+	}
+	if cfg.Server.TLSMinVersion != "" && !o.flagChanged("tls-min-version") {
+		o.TLSMinVersion = cfg.Server.TLSMinVersion
+	}
+	if cfg.Server.EnablePProf != nil && !o.flagChanged("enable-pprof") {
+		o.EnablePProf = *cfg.Server.EnablePProf
+	}
+	if cfg.Server.ShutdownDrainPeriod != nil && !o.flagChanged("shutdown-drain-period") {
+		o.ShutdownDrainPeriod = cfg.Server.ShutdownDrainPeriod.Duration
+	}
+	if cfg.Cache.AutoPreCacheResources != nil && !o.flagChanged("auto-pre-cache-resources") {
+		o.AutoPreCacheResources = *cfg.Cache.AutoPreCacheResources
+	}
+	if cfg.DataSource.KubeAPIQPS != 0 && !o.flagChanged("kube-api-qps") {
+		o.KubeAPIQPS = cfg.DataSource.KubeAPIQPS
+	}
+	if cfg.DataSource.KubeAPIBurst != 0 && !o.flagChanged("kube-api-burst") {
+		o.KubeAPIBurst = cfg.DataSource.KubeAPIBurst
+	}
+	o.applyCertConfig(cfg.Cert)
+
+	return o.ApplyReloadableConfig(cfg)
+}
+
+// ApplyReloadableConfig applies the subset of cfg which is safe to change at runtime:
+// log verbosity, pre-cache resources and allowlist. The file is authoritative for options whose flags were
+// not set explicitly, options absent from it fall back to the defaults of their flags.
+func (o *Options) ApplyReloadableConfig(cfg *configv1alpha1.WebhookConfiguration) error {
+	if !o.flagChanged("v") && o.flags != nil {
+		if f := o.flags.Lookup("v"); f != nil {
+			verbosity := f.DefValue
+			if cfg.Logging.Verbosity != nil {
+				verbosity = strconv.Itoa(int(*cfg.Logging.Verbosity))
+			}
+			if err := f.Value.Set(verbosity); err != nil {
+				return err
+			}
+		}
+	}
+	if !o.flagChanged("pre-cache-resources") {
+		// the flag defaults to none
+		resources := NewPreCacheResources([]string{})
+		if err := resources.Replace(cfg.Cache.PreCacheResources); err != nil {
+			return err
+		}
+		o.PreCacheResources = resources
+	}
+	o.Allowlist = cfg.Allowlist
+
+	return nil
+}
+
+// StaticConfigChanged returns true if settings which require a restart differ between old and new.
+func StaticConfigChanged(old, new *configv1alpha1.WebhookConfiguration) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+
+	return !reflect.DeepEqual(old.Server, new.Server) ||
+		!reflect.DeepEqual(old.Cache.AutoPreCacheResources, new.Cache.AutoPreCacheResources) ||
+		!reflect.DeepEqual(old.Cert, new.Cert) ||
+		!reflect.DeepEqual(old.DataSource, new.DataSource)
+}
+
+func (o *Options) flagChanged(name string) bool {
+	if o.flags == nil {
+		return false
+	}
+
+	return o.flags.Changed(name)
+}
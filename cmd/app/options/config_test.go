@@ -0,0 +1,177 @@
+package options
+
+import (
+	goflag "flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+const testConfig = `apiVersion: config.kcloudlabs.io/v1alpha1
+kind: WebhookConfiguration
+server:
+  securePort: 9443
+  tlsMinVersion: "1.2"
+cache:
+  preCacheResources:
+    - Pod/v1
+    - Deployment/apps/v1
+cert:
+  namespace: kinitiras
+  serviceName: kinitiras-webhook-svc
+  lookahead: 720h
+dataSource:
+  kubeAPIQPS: 100
+logging:
+  verbosity: 4
+allowlist:
+  namespaces:
+    - kube-system
+`
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "1",
+			content: testConfig,
+			wantErr: false,
+		},
+		{
+			name:    "unknown field",
+			content: "apiVersion: config.kcloudlabs.io/v1alpha1\nkind: WebhookConfiguration\nfoo: bar\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfigFile(writeConfig(t, tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if errs := ValidateConfig(cfg, nil); len(errs) != 0 {
+				t.Errorf("ValidateConfig() = %v", errs)
+			}
+		})
+	}
+
+	if _, err := LoadConfigFile(filepath.Join(t.TempDir(), "not-exist.yaml")); err == nil {
+		t.Errorf("LoadConfigFile() expected error for missing file")
+	}
+}
+
+func TestOptions_Complete(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	opts := NewOptions()
+	opts.AddFlags(flags)
+	t.Setenv(EnvNamespace, "kinitiras-env")
+	t.Setenv(EnvSecretName, "kinitiras-env-cert")
+	t.Setenv(EnvCAName, "kinitiras-env-ca")
+	if err := flags.Parse([]string{"--secure-port=8443", "--cert-secret-name=kinitiras-cert", "--config=" + writeConfig(t, testConfig)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := opts.Complete(); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	// explicitly set flags take precedence
+	if opts.SecurePort != 8443 {
+		t.Errorf("SecurePort = %v, want 8443", opts.SecurePort)
+	}
+	if opts.TLSMinVersion != "1.2" {
+		t.Errorf("TLSMinVersion = %v, want 1.2", opts.TLSMinVersion)
+	}
+	if opts.KubeAPIQPS != 100 {
+		t.Errorf("KubeAPIQPS = %v, want 100", opts.KubeAPIQPS)
+	}
+	if got := opts.PreCacheResources.GetSlice(); len(got) != 2 {
+		t.Errorf("PreCacheResources = %v, want 2 items", got)
+	}
+	// flags, then the file, then environment variables
+	certOpts := opts.CertOptions()
+	if certOpts.SecretName != "kinitiras-cert" || certOpts.Namespace != "kinitiras" || certOpts.CAName != "kinitiras-env-ca" ||
+		certOpts.ServiceName != "kinitiras-webhook-svc" || certOpts.Lookahead != 720*time.Hour {
+		t.Errorf("CertOptions() = %+v", certOpts)
+	}
+	if len(opts.Allowlist.Namespaces) != 1 {
+		t.Errorf("Allowlist = %v, want 1 namespace", opts.Allowlist)
+	}
+	if errs := opts.Validate(); len(errs) != 0 {
+		t.Errorf("Validate() = %v", errs)
+	}
+}
+
+func TestStaticConfigChanged(t *testing.T) {
+	old, err := LoadConfigFile(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded := *old
+	reloaded.Allowlist.Users = []string{"admin"}
+	if StaticConfigChanged(old, &reloaded) {
+		t.Errorf("StaticConfigChanged() = true for reloadable change")
+	}
+
+	reloaded.Server.SecurePort = 443
+	if !StaticConfigChanged(old, &reloaded) {
+		t.Errorf("StaticConfigChanged() = false for server change")
+	}
+}
+
+func TestOptions_ApplyReloadableConfig(t *testing.T) {
+	// the verbosity of klog is global, the default of the flag is the verbosity when it is added
+	klogFlags := goflag.NewFlagSet("klog", goflag.ContinueOnError)
+	klog.InitFlags(klogFlags)
+	if err := klogFlags.Set("v", "0"); err != nil {
+		t.Fatal(err)
+	}
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	opts := NewOptions()
+	opts.AddFlags(flags)
+	if err := flags.Parse([]string{"--config=" + writeConfig(t, testConfig)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.Complete(); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if v := flags.Lookup("v").Value.String(); v != "4" {
+		t.Errorf("v = %v, want 4", v)
+	}
+
+	// removed values fall back to the defaults of their flags
+	cfg, err := LoadConfigFile(writeConfig(t, "apiVersion: config.kcloudlabs.io/v1alpha1\nkind: WebhookConfiguration\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.ApplyReloadableConfig(cfg); err != nil {
+		t.Fatalf("ApplyReloadableConfig() error = %v", err)
+	}
+	if v := flags.Lookup("v").Value.String(); v != "0" {
+		t.Errorf("v = %v, want 0", v)
+	}
+	if got := opts.PreCacheResources.GetSlice(); len(got) != 0 {
+		t.Errorf("PreCacheResources = %v, want none", got)
+	}
+	if len(opts.Allowlist.Namespaces) != 0 {
+		t.Errorf("Allowlist = %v, want none", opts.Allowlist)
+	}
+}
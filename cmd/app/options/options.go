@@ -2,13 +2,21 @@ package options
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/component-base/cli/globalflag"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	configv1alpha1 "github.com/k-cloud-labs/kinitiras/pkg/apis/config/v1alpha1"
+	"github.com/k-cloud-labs/kinitiras/pkg/controller/cert"
+	"github.com/k-cloud-labs/kinitiras/pkg/util/tlsconfig"
 )
 
 const (
@@ -16,8 +24,20 @@ const (
 	defaultPort          = 8443
 	defaultCertDir       = "/tmp/k8s-webhook-server/serving-certs"
 	defaultTLSMinVersion = "1.3"
+	// defaultShutdownDrainPeriod covers a few failed readiness probes and the propagation of endpoints.
+	defaultShutdownDrainPeriod = 10 * time.Second
+)
+
+// Possible values of --verify-mutation.
+const (
+	VerifyMutationNone = "None"
+	VerifyMutationWarn = "Warn"
+	VerifyMutationDeny = "Deny"
 )
 
+// resourceNameRegexp matches resource names, short names and resource.group.
+var resourceNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([-a-zA-Z0-9.]*[a-zA-Z0-9])?$`)
+
 // Options contains everything necessary to create and run webhook server.
 type Options struct {
 	// BindAddress is the IP address on which to listen for the --secure-port port.
@@ -29,23 +49,120 @@ type Options struct {
 	// MetricsBindAddress is the IP:Port address on which to listen for the webhook metrics.
 	// Default is ":8080".
 	MetricsBindAddress string
+	// SecureMetrics is switch to serve metrics over HTTPS, authenticating requests by TokenReview and
+	// authorizing them by SubjectAccessReview of /metrics. Default value as false.
+	SecureMetrics bool
+	// MetricsCertDir is the directory that contains the key and certificate of the secure metrics server,
+	// named tls.key and tls.crt. Default to CertDir, the certificate of the webhook server.
+	MetricsCertDir string
 	// CertDir is the directory that contains the server key and certificate.
 	// if not set, webhook server would look up the server key and certificate in {TempDir}/k8s-webhook-server/serving-certs.
 	// The server key and certificate must be named `tls.key` and `tls.crt`, respectively.
 	CertDir string
+	// SelfSignedCerts is switch to generate and rotate self-signed certificates in CertDir, and inject the CA into the
+	// webhook configurations. Disable it to use certificates provided by others, e.g. cert-manager. Default value as true.
+	SelfSignedCerts bool
+	// CertNamespace is the namespace of the secret which stores self-signed certificates.
+	// Fall back to the NAMESPACE environment variable. Default to kinitiras-system.
+	CertNamespace string
+	// CertSecretName is the name of the secret which stores self-signed certificates.
+	// Fall back to the SECRET environment variable. Default to kinitiras-webhook-cert.
+	CertSecretName string
+	// CertCAName is the common name of the generated CA. Fall back to the CA_NAME environment variable.
+	// Default to kinitiras-ca.
+	CertCAName string
+	// CertCAOrganization is the organization of the generated CA. Fall back to the CA_ORGANIZATION environment variable.
+	// Default to kinitiras.
+	CertCAOrganization string
+	// CertServiceName is the name of the service in front of the webhook server, the serving certificate is issued for
+	// <CertServiceName>.<CertNamespace>.svc. Fall back to the SERVICE_NAME environment variable. Default to kinitiras-webhook.
+	CertServiceName string
+	// CertMutatingConfig is the name of the MutatingWebhookConfiguration of kinitiras.
+	// Fall back to the MUTATING_CONFIG environment variable. Default to kinitiras-webhook.
+	CertMutatingConfig string
+	// CertValidatingConfig is the name of the ValidatingWebhookConfiguration of kinitiras.
+	// Fall back to the VALIDATING_CONFIG environment variable. Default to kinitiras-webhook.
+	CertValidatingConfig string
+	// CertExtraDNSNames are the DNS names of the serving certificate besides <CertServiceName>.<CertNamespace>.svc.
+	CertExtraDNSNames []string
+	// CertValidity is the validity of the generated CA and serving certificate. Default to 10 years, which 0 uses as well.
+	CertValidity time.Duration
+	// CertLookahead is how long before expiration certificates are rotated, it must be shorter than CertValidity.
+	// Default to 90 days, which 0 uses as well.
+	CertLookahead time.Duration
+	// CertCABundleTargets are additional webhook configurations, CRDs and APIServices to inject the CA into,
+	// in Kind/name format.
+	CertCABundleTargets []string
+	// CertCABundleSelector is a label selector of more webhook configurations, CRDs and APIServices to inject the CA into.
+	CertCABundleSelector string
 	// TLSMinVersion is the minimum version of TLS supported. Possible values: 1.0, 1.1, 1.2, 1.3.
 	// Some environments have automated security scans that trigger on TLS versions or insecure cipher suites, and
 	// setting TLS to 1.3 would solve both problems.
 	// Defaults to 1.3.
 	TLSMinVersion string
+	// TLSCipherSuites are the cipher suites of TLS 1.2 and below supported by the webhook and metrics servers,
+	// empty means the ones of TLSProfile.
+	TLSCipherSuites []string
+	// TLSCurvePreferences are the elliptic curves used in ECDHE handshakes, empty means the ones of TLSProfile.
+	TLSCurvePreferences []string
+	// TLSProfile is a preset of cipher suites and curves. Possible values: FIPS. Default to the ones of Go.
+	TLSProfile string
+	// ClientCAFile is the CA bundle verifying client certificates of requests to /mutate and /validate, e.g. the CA of
+	// the webhook client certificate of kube-apiserver. Client certificates are not verified if it is empty.
+	ClientCAFile string
+	// AllowedClientNames are the common names and DNS names allowed in client certificates verified by ClientCAFile,
+	// empty means any verified certificate.
+	AllowedClientNames []string
 	// KubeAPIQPS is the QPS to use while talking with kube-apiserver.
 	KubeAPIQPS float32
 	// KubeAPIBurst is the burst to allow while talking with kube-apiserver.
 	KubeAPIBurst int
 	// PreCacheResources is a list of resources name to pre-cache when start up.
 	PreCacheResources *ResourceSlice
+	// AutoPreCacheResources is switch to cache the resources referred by policies automatically,
+	// in addition to PreCacheResources. Default value as true.
+	AutoPreCacheResources bool
+	// ManageWebhookRules is switch to keep rules of kinitiras webhook configurations narrowed to
+	// the resources and operations selected by policies, and namespaceSelector excluding namespaces
+	// on the allowlist. Default value as false.
+	ManageWebhookRules bool
+	// VerifyMutation decides how the mutating webhook handles a mutated object which violates
+	// ClusterValidatePolicies. Possible values: None, Warn, Deny. Default value as None.
+	VerifyMutation string
+	// StampAppliedGeneration is switch to stamp a hash of the policies selecting an object and the UID of the
+	// request on mutated objects, so reinvocations of the same request are no-ops. Default value as false.
+	StampAppliedGeneration bool
+	// CheckIdempotency is switch to apply override policies twice and report policies which are not idempotent.
+	// Default value as false.
+	CheckIdempotency bool
+	// EnableDeleteHooks is switch to run the delete hook controller, which holds the deletion of objects with the
+	// kinitiras.kcloudlabs.io/delete-hook finalizer until delete hook policies pass. Default value as false.
+	EnableDeleteHooks bool
+	// ShutdownDrainPeriod is the period to keep serving admission requests with failing readiness after
+	// a termination signal, before informers and listeners are stopped. Default value as 10s.
+	ShutdownDrainPeriod time.Duration
+	// EnableDebugHandlers is switch to serve read-only policy debugging endpoints on the metrics server.
+	// Default value as false.
+	EnableDebugHandlers bool
+	// DebugTokenFile is the file containing the bearer token required by pprof and the debugging endpoints.
+	DebugTokenFile string
+	// DebugDelegatedAuth is switch to authenticate requests to pprof and the debugging endpoints by TokenReview,
+	// and authorize them by SubjectAccessReview of their paths. Default value as false.
+	DebugDelegatedAuth bool
+	// DebugBindAddress is the IP:Port address to serve pprof and the debugging endpoints on,
+	// empty means serving them on the metrics server.
+	DebugBindAddress string
 	// EnablePProf is switch to enable/disable net/http/pprof. Default value as false.
 	EnablePProf bool
+	// ConfigFile is the path of the configuration file. Flags set explicitly take precedence over it.
+	ConfigFile string
+	// Config is the configuration loaded from ConfigFile, nil if no file was given.
+	Config *configv1alpha1.WebhookConfiguration
+	// Allowlist contains requests which always bypass policies. Only set via configuration file.
+	Allowlist configv1alpha1.AllowlistConfiguration
+
+	// flags is used to tell which options were set explicitly on the command line.
+	flags *pflag.FlagSet
 }
 
 // NewOptions builds an empty options.
@@ -55,6 +172,7 @@ func NewOptions() *Options {
 
 // AddFlags adds flags to the specified FlagSet.
 func (o *Options) AddFlags(flags *pflag.FlagSet) {
+	o.flags = flags
 	o.PreCacheResources = NewPreCacheResources([]string{})
 	flags.StringVar(&o.BindAddress, "bind-address", defaultBindAddress,
 		"The IP address on which to listen for the --secure-port port.")
@@ -62,14 +180,85 @@ func (o *Options) AddFlags(flags *pflag.FlagSet) {
 		"The secure port on which to serve HTTPS.")
 	flags.StringVar(&o.MetricsBindAddress, "metrics-bind-address", metrics.DefaultBindAddress,
 		"The Metrics bind address on which to listen for the webhook metrics.")
+	flags.BoolVar(&o.SecureMetrics, "secure-metrics", false, "Serve metrics on --metrics-bind-address over HTTPS. Requests are authenticated by TokenReview "+
+		"and authorized by SubjectAccessReview of verb get on nonResourceURL /metrics.")
+	flags.StringVar(&o.MetricsCertDir, "metrics-cert-dir", "", "The directory that contains the key(named tls.key) and certificate(named tls.crt) "+
+		"of the secure metrics server. Default to --cert-dir.")
 	flags.StringVar(&o.CertDir, "cert-dir", defaultCertDir,
 		"The directory that contains the server key(named tls.key) and certificate(named tls.crt).")
+	flags.BoolVar(&o.SelfSignedCerts, "self-signed-certs", true, "Generate and rotate self-signed certificates in --cert-dir, "+
+		"and inject the CA into the webhook configurations. Disable it to use certificates provided by others, e.g. mounted from a secret of cert-manager.")
+	flags.StringVar(&o.CertNamespace, "cert-namespace", cert.DefaultNamespace, "The namespace of the secret which stores self-signed certificates. "+
+		"Fall back to the NAMESPACE environment variable if not set.")
+	flags.StringVar(&o.CertSecretName, "cert-secret-name", cert.DefaultSecretName, "The name of the secret which stores self-signed certificates. "+
+		"Fall back to the SECRET environment variable if not set.")
+	flags.StringVar(&o.CertCAName, "cert-ca-name", cert.DefaultCAName, "The common name of the generated CA. "+
+		"Fall back to the CA_NAME environment variable if not set.")
+	flags.StringVar(&o.CertCAOrganization, "cert-ca-organization", cert.DefaultCAOrganization, "The organization of the generated CA. "+
+		"Fall back to the CA_ORGANIZATION environment variable if not set.")
+	flags.StringVar(&o.CertServiceName, "cert-service-name", cert.DefaultServiceName, "The name of the service in front of the webhook server, "+
+		"the serving certificate is issued for <name>.<cert-namespace>.svc. Fall back to the SERVICE_NAME environment variable if not set.")
+	flags.StringVar(&o.CertMutatingConfig, "cert-mutating-config", cert.DefaultServiceName, "The name of the MutatingWebhookConfiguration of kinitiras. "+
+		"Fall back to the MUTATING_CONFIG environment variable if not set.")
+	flags.StringVar(&o.CertValidatingConfig, "cert-validating-config", cert.DefaultServiceName, "The name of the ValidatingWebhookConfiguration of kinitiras. "+
+		"Fall back to the VALIDATING_CONFIG environment variable if not set.")
+	flags.StringSliceVar(&o.CertExtraDNSNames, "cert-extra-dns-names", nil, "Comma-separated list of DNS names of the serving certificate "+
+		"besides <cert-service-name>.<cert-namespace>.svc, e.g. for a webhook reached by URL.")
+	flags.DurationVar(&o.CertValidity, "cert-validity", cert.DefaultValidity, "The validity of the generated CA and serving certificate. 0 uses the default.")
+	flags.DurationVar(&o.CertLookahead, "cert-lookahead", cert.DefaultLookahead, "How long before expiration certificates are rotated. "+
+		"It must be shorter than --cert-validity, 0 uses the default.")
+	flags.StringSliceVar(&o.CertCABundleTargets, "cert-ca-bundle-targets", nil, "Comma-separated list of additional objects to inject the CA into, "+
+		"in Kind/name format. Possible kinds: MutatingWebhookConfiguration, ValidatingWebhookConfiguration, CustomResourceDefinition (conversion webhook), "+
+		"APIService. For example: CustomResourceDefinition/foos.example.com,APIService/v1.example.com.")
+	flags.StringVar(&o.CertCABundleSelector, "cert-ca-bundle-selector", "", "A label selector of more objects to inject the CA into, "+
+		"e.g. app=kinitiras. MutatingWebhookConfigurations, ValidatingWebhookConfigurations, CustomResourceDefinitions with conversion webhooks "+
+		"and APIServices are selected.")
 	flags.StringVar(&o.TLSMinVersion, "tls-min-version", defaultTLSMinVersion, "Minimum TLS version supported. Possible values: 1.0, 1.1, 1.2, 1.3.")
+	flags.StringSliceVar(&o.TLSCipherSuites, "tls-cipher-suites", nil, "Comma-separated list of cipher suites of TLS 1.2 and below, "+
+		"e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Cipher suites of TLS 1.3 are not configurable. Default to the ones of --tls-profile.")
+	flags.StringSliceVar(&o.TLSCurvePreferences, "tls-curve-preferences", nil, "Comma-separated list of elliptic curves used in ECDHE handshakes. "+
+		"Possible values: X25519, P256, P384, P521. Default to the ones of --tls-profile.")
+	flags.StringVar(&o.TLSProfile, "tls-profile", "", "A preset of cipher suites and curves, overridden by --tls-cipher-suites and --tls-curve-preferences. "+
+		"Possible values: FIPS, which allows ECDHE with AES-GCM and curves P256 and P384 only, and requires --tls-min-version 1.2 or above. "+
+		"Default to the cipher suites and curves of Go.")
+	flags.StringVar(&o.ClientCAFile, "client-ca-file", "", "The CA bundle verifying client certificates of requests to /mutate and /validate, "+
+		"e.g. the CA of the webhook client certificate of kube-apiserver. Requests without a verified certificate are rejected. Probes are served without.")
+	flags.StringSliceVar(&o.AllowedClientNames, "allowed-client-names", nil, "Common names and DNS names allowed in client certificates "+
+		"verified by --client-ca-file. Any verified certificate is allowed if it is empty.")
 	flags.Float32Var(&o.KubeAPIQPS, "kube-api-qps", 40.0, "QPS to use while talking with kube-apiserver. Doesn't cover events and node heartbeat apis which rate limiting is controlled by a different set of flags.")
 	flags.IntVar(&o.KubeAPIBurst, "kube-api-burst", 60, "Burst to use while talking with kube-apiserver. Doesn't cover events and node heartbeat apis which rate limiting is controlled by a different set of flags.")
 	flags.VarP(o.PreCacheResources, "pre-cache-resources", "", "Resources list separate by comma, for example: Pod/v1,Deployment/apps/v1"+
+		". Resource names, short names and resource.group are accepted as well, for example: pods,deploy,deployments.apps"+
 		". Will pre cache those resources to get it quicker when policies refer resources from cluster.")
-	flags.BoolVar(&o.EnablePProf, "enable-pprof", false, "EnablePProf is switch to enable/disable net/http/pprof. Default value as false.")
+	flags.BoolVar(&o.AutoPreCacheResources, "auto-pre-cache-resources", true, "Cache resources referred by policies automatically when policies are added. "+
+		"They stay cached until restart when no policy refers them anymore, since the dynamic lister can not stop caching resources. "+
+		"Resources given by --pre-cache-resources are always cached.")
+	flags.BoolVar(&o.ManageWebhookRules, "manage-webhook-rules", false, "Keep rules of the mutating and validating webhook configurations "+
+		"narrowed to the resources and operations selected by policies, so apiserver only calls the webhook when a policy may apply. "+
+		"Namespaces on the allowlist are excluded by namespaceSelector as well.")
+	flags.StringVar(&o.VerifyMutation, "verify-mutation", VerifyMutationNone, "Apply ClusterValidatePolicies to objects mutated by override policies in the mutating webhook. "+
+		"Possible values: None, Warn, Deny. Warn returns a warning naming the override and validate policies to the client, Deny rejects the request.")
+	flags.BoolVar(&o.StampAppliedGeneration, "stamp-applied-generation", false, "Stamp the kinitiras.kcloudlabs.io/applied-generation annotation on mutated objects. "+
+		"When the webhook is reinvoked in the same request for an object mutated by the same policies, the request is allowed without applying policies again.")
+	flags.BoolVar(&o.CheckIdempotency, "check-idempotency", false, "Apply override policies twice and report policies which change the object again "+
+		"by logs, warnings and the non-idempotent-override-policies audit annotation. It doubles the cost of override policies, so use it for testing.")
+	flags.BoolVar(&o.EnableDeleteHooks, "enable-delete-hooks", false, "Run the delete hook controller. ClusterValidatePolicies annotated with "+
+		"kinitiras.kcloudlabs.io/delete-hook=true hold the deletion of objects with the kinitiras.kcloudlabs.io/delete-hook finalizer until they pass, "+
+		"instead of rejecting DELETE requests. Labels and annotations set by override policies on DELETE are applied to the objects being deleted.")
+	flags.DurationVar(&o.ShutdownDrainPeriod, "shutdown-drain-period", defaultShutdownDrainPeriod, "The period to keep serving admission requests "+
+		"after a termination signal. Readiness fails during the period, so the webhook is removed from endpoints of its service before listeners are closed. "+
+		"It should be shorter than terminationGracePeriodSeconds of the pod.")
+	flags.BoolVar(&o.EnableDebugHandlers, "enable-debug-handlers", false, "Serve read-only policy debugging endpoints under /debug/kinitiras/ "+
+		"on the metrics server: loaded policies, cue of rules, cached resources and evaluation of an uploaded object.")
+	flags.StringVar(&o.DebugTokenFile, "debug-token-file", "", "The file containing the bearer token required by pprof and the debugging endpoints. "+
+		"The endpoints are not authenticated if neither it nor --debug-delegated-auth is set.")
+	flags.BoolVar(&o.DebugDelegatedAuth, "debug-delegated-auth", false, "Authenticate requests to pprof and the debugging endpoints by TokenReview, "+
+		"and authorize them by SubjectAccessReview of the request path, e.g. verb get on nonResourceURL /debug/pprof/*.")
+	flags.StringVar(&o.DebugBindAddress, "debug-bind-address", "", "The IP:Port address to serve pprof and the debugging endpoints on. "+
+		"They are served on the metrics server if it is empty.")
+	flags.BoolVar(&o.EnablePProf, "enable-pprof", false, "Serve net/http/pprof under /debug/pprof/ on the metrics server, or on --debug-bind-address if it is set.")
+	flags.StringVar(&o.ConfigFile, "config", "", "The path of the configuration file. Flags set explicitly take precedence over values in the file. "+
+		"Log verbosity, pre-cache resources and allowlist are reloaded when the file changes.")
 
 	globalflag.AddGlobalFlags(flags, "global")
 }
@@ -81,9 +270,17 @@ func PrintFlags(flags *pflag.FlagSet) {
 	})
 }
 
+// ResourceSlice is a list of resources given in one of the formats below:
+//   - Kind/version or Kind/group/version, e.g. Pod/v1, Deployment/apps/v1
+//   - resource name or short name, e.g. pods, po, deploy
+//   - resource.group or resource.version.group, e.g. deployments.apps, deployments.v1.apps
+//
+// Only the first format can be converted to kinds directly, others are kept unresolved
+// until Resolve is called with a RESTMapper.
 type ResourceSlice struct {
-	value   *[]schema.GroupVersionKind
-	changed bool
+	value      *[]schema.GroupVersionKind
+	unresolved []string
+	changed    bool
 }
 
 func NewPreCacheResources(slice []string) *ResourceSlice {
@@ -94,21 +291,12 @@ func NewPreCacheResources(slice []string) *ResourceSlice {
 }
 
 func (s *ResourceSlice) String() string {
-	sb := &strings.Builder{}
-
 	// check if the value is nil
 	if s.value == nil {
 		return "[]"
 	}
 
-	for i, gvk := range *s.value {
-		if i != 0 {
-			sb.WriteString(",")
-		}
-		sb.WriteString(gvk.Kind + "/" + gvk.GroupVersion().String())
-	}
-
-	return "[" + sb.String() + "]"
+	return "[" + strings.Join(s.GetSlice(), ",") + "]"
 }
 
 func (s *ResourceSlice) Set(val string) error {
@@ -117,16 +305,15 @@ func (s *ResourceSlice) Set(val string) error {
 	}
 	if !s.changed {
 		*s.value = make([]schema.GroupVersionKind, 0)
+		s.unresolved = nil
 	}
 
 	// split by comma
 	vals := strings.Split(val, ",")
 	for _, v := range vals {
-		gvk, err := s.readResource(v)
-		if err != nil {
+		if err := s.Append(v); err != nil {
 			return err
 		}
-		*s.value = append(*s.value, gvk)
 		s.changed = true
 	}
 
@@ -138,44 +325,121 @@ func (s *ResourceSlice) Type() string {
 }
 
 func (s *ResourceSlice) Append(val string) error {
-	gvk, err := s.readResource(val)
+	gvk, unresolved, err := s.readResource(val)
 	if err != nil {
 		return err
 	}
 
+	if unresolved != "" {
+		s.unresolved = append(s.unresolved, unresolved)
+		return nil
+	}
+
 	*s.value = append(*s.value, gvk)
 	return nil
 }
 
 func (s *ResourceSlice) Replace(slice []string) error {
 	value := make([]schema.GroupVersionKind, 0, len(slice))
+	var unresolved []string
 	for _, str := range slice {
-		gvk, err := s.readResource(str)
+		gvk, name, err := s.readResource(str)
 		if err != nil {
 			return err
 		}
 
+		if name != "" {
+			unresolved = append(unresolved, name)
+			continue
+		}
 		value = append(value, gvk)
 	}
 
 	*s.value = value
+	s.unresolved = unresolved
 	return nil
 }
 
 func (s *ResourceSlice) GetSlice() []string {
-	var slice = make([]string, 0, len(*s.value))
+	var slice = make([]string, 0, len(*s.value)+len(s.unresolved))
 	for _, gvk := range *s.value {
 		slice = append(slice, gvk.Kind+"/"+gvk.GroupVersion().String())
 	}
 
-	return slice
+	return append(slice, s.unresolved...)
+}
+
+// Resolve converts unresolved resource names to kinds and checks every resource is served by
+// the cluster. Resources which can be resolved are kept even if others fail.
+func (s *ResourceSlice) Resolve(mapper meta.RESTMapper) error {
+	var errs []error
+	value := make([]schema.GroupVersionKind, 0, len(*s.value)+len(s.unresolved))
+	seen := make(map[schema.GroupVersionKind]struct{}, cap(value))
+	add := func(gvk schema.GroupVersionKind) {
+		if _, ok := seen[gvk]; !ok {
+			seen[gvk] = struct{}{}
+			value = append(value, gvk)
+		}
+	}
+
+	for _, gvk := range *s.value {
+		if _, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			errs = append(errs, fmt.Errorf("unknown resource %q: %v", gvk.Kind+"/"+gvk.GroupVersion().String(), err))
+			continue
+		}
+		add(gvk)
+	}
+
+	for _, name := range s.unresolved {
+		gvk, err := resolveResourceName(mapper, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		add(gvk)
+	}
+
+	*s.value = value
+	s.unresolved = nil
+	return utilerrors.NewAggregate(errs)
+}
+
+// resolveResourceName finds the kind of a resource name the way kubectl does.
+func resolveResourceName(mapper meta.RESTMapper, name string) (schema.GroupVersionKind, error) {
+	fullySpecifiedGVR, groupResource := schema.ParseResourceArg(strings.ToLower(name))
+	if fullySpecifiedGVR != nil {
+		if gvk, err := mapper.KindFor(*fullySpecifiedGVR); err == nil && !gvk.Empty() {
+			return gvk, nil
+		}
+	}
+
+	gvk, err := mapper.KindFor(groupResource.WithVersion(""))
+	if err != nil {
+		return gvk, fmt.Errorf("unknown resource %q: %v", name, err)
+	}
+
+	return gvk, nil
 }
 
-func (s *ResourceSlice) readResource(val string) (schema.GroupVersionKind, error) {
-	var gvk schema.GroupVersionKind
+// readResource parses val in Kind/version or Kind/group/version format. Values in other formats
+// are returned as unresolved, to be resolved by Resolve.
+func (s *ResourceSlice) readResource(val string) (gvk schema.GroupVersionKind, unresolved string, err error) {
+	val = strings.TrimSpace(val)
+	if !strings.Contains(val, "/") {
+		if !resourceNameRegexp.MatchString(val) {
+			return gvk, "", fmt.Errorf("invalid resource(%v), expects Kind/version, Kind/group/version, resource or resource.group", val)
+		}
+		return gvk, val, nil
+	}
+
 	items := strings.Split(val, "/")
-	if len(items) <= 1 {
-		return gvk, fmt.Errorf("invalid gvk(%v)", val)
+	if len(items) > 3 {
+		return gvk, "", fmt.Errorf("invalid gvk(%v)", val)
+	}
+	for _, item := range items {
+		if item == "" {
+			return gvk, "", fmt.Errorf("invalid gvk(%v)", val)
+		}
 	}
 
 	gvk.Kind = items[0]
@@ -186,9 +450,41 @@ func (s *ResourceSlice) readResource(val string) (schema.GroupVersionKind, error
 		gvk.Version = items[2]
 	}
 
-	return gvk, nil
+	return gvk, "", nil
+}
+
+// TLSConfig returns the TLS settings of the webhook and metrics servers.
+func (o *Options) TLSConfig() (tlsconfig.Config, error) {
+	minVersion, err := tlsconfig.Version(o.TLSMinVersion)
+	if err != nil {
+		return tlsconfig.Config{}, err
+	}
+	cipherSuiteNames, curveNames, err := tlsconfig.Profile(o.TLSProfile)
+	if err != nil {
+		return tlsconfig.Config{}, err
+	}
+	if len(o.TLSCipherSuites) != 0 {
+		cipherSuiteNames = o.TLSCipherSuites
+	}
+	if len(o.TLSCurvePreferences) != 0 {
+		curveNames = o.TLSCurvePreferences
+	}
+
+	config := tlsconfig.Config{MinVersion: minVersion}
+	if len(cipherSuiteNames) != 0 {
+		if config.CipherSuites, err = tlsconfig.CipherSuites(cipherSuiteNames); err != nil {
+			return tlsconfig.Config{}, err
+		}
+	}
+	if len(curveNames) != 0 {
+		if config.CurvePreferences, err = tlsconfig.CurvePreferences(curveNames); err != nil {
+			return tlsconfig.Config{}, err
+		}
+	}
+	return config, nil
 }
 
+// PreCacheResourcesToGVKList returns the resolved pre-cache resources.
 func (o *Options) PreCacheResourcesToGVKList() []schema.GroupVersionKind {
 	return *o.PreCacheResources.value
 }
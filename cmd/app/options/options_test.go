@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestPrintFlags(t *testing.T) {
@@ -59,10 +61,20 @@ func TestResourceSlice_Set(t *testing.T) {
 				s: NewPreCacheResources([]string{"Pod/v1"}),
 			},
 			args: args{
-				val: "v1",
+				val: "Pod/",
 			},
 			wantErr: true,
 		},
+		{
+			name: "resource names",
+			fields: fields{
+				s: NewPreCacheResources([]string{"Pod/v1"}),
+			},
+			args: args{
+				val: "pods,deploy,deployments.apps",
+			},
+			wantErr: false,
+		},
 		{
 			name: "error2",
 			fields: fields{
@@ -114,10 +126,20 @@ func TestResourceSlice_Append(t *testing.T) {
 				s: NewPreCacheResources([]string{"Pod/v1"}),
 			},
 			args: args{
-				val: "Node",
+				val: "Node/apps/v1/v2",
 			},
 			wantErr: true,
 		},
+		{
+			name: "short name",
+			fields: fields{
+				s: NewPreCacheResources([]string{"Pod/v1"}),
+			},
+			args: args{
+				val: "no",
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -145,6 +167,13 @@ func TestResourceSlice_GetSlice(t *testing.T) {
 			},
 			want: []string{"Pod/v1", "Deployment/apps/v1"},
 		},
+		{
+			name: "unresolved",
+			fields: fields{
+				s: NewPreCacheResources([]string{"Pod/v1", "deployments.apps"}),
+			},
+			want: []string{"Pod/v1", "deployments.apps"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -185,7 +214,7 @@ func TestResourceSlice_Replace(t *testing.T) {
 				s: NewPreCacheResources([]string{"Pod/v1"}),
 			},
 			args: args{
-				slice: []string{"Node"},
+				slice: []string{"/v1"},
 			},
 			wantErr: true,
 		},
@@ -199,3 +228,41 @@ func TestResourceSlice_Replace(t *testing.T) {
 		})
 	}
 }
+
+func TestResourceSlice_Resolve(t *testing.T) {
+	podGVK := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	deployGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{podGVK.GroupVersion(), deployGVK.GroupVersion()})
+	mapper.Add(podGVK, meta.RESTScopeNamespace)
+	mapper.Add(deployGVK, meta.RESTScopeNamespace)
+
+	tests := []struct {
+		name    string
+		s       *ResourceSlice
+		want    []schema.GroupVersionKind
+		wantErr bool
+	}{
+		{
+			name: "resource names",
+			s:    NewPreCacheResources([]string{"Pod/v1", "pods", "deployments.apps", "deployments.v1.apps"}),
+			want: []schema.GroupVersionKind{podGVK, deployGVK},
+		},
+		{
+			name:    "unknown resource",
+			s:       NewPreCacheResources([]string{"Pod/v1", "Foo/v1", "bars"}),
+			want:    []schema.GroupVersionKind{podGVK},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.s.Resolve(mapper)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := *tt.s.value; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,22 @@
 package options
 
 import (
+	"crypto/tls"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	pkgallowlist "github.com/k-cloud-labs/kinitiras/pkg/allowlist"
+	configv1alpha1 "github.com/k-cloud-labs/kinitiras/pkg/apis/config/v1alpha1"
+	"github.com/k-cloud-labs/kinitiras/pkg/controller/cert"
+	"github.com/k-cloud-labs/kinitiras/pkg/util/tlsconfig"
 )
 
 // Validate checks Options and return a slice of found errs.
@@ -19,5 +32,241 @@ func (o *Options) Validate() field.ErrorList {
 		errs = append(errs, field.Invalid(newPath.Child("SecurePort"), o.SecurePort, "must be a valid port between 0 and 65535 inclusive"))
 	}
 
+	if o.MetricsBindAddress != "" && o.MetricsBindAddress != "0" {
+		if _, _, err := net.SplitHostPort(o.MetricsBindAddress); err != nil {
+			errs = append(errs, field.Invalid(newPath.Child("MetricsBindAddress"), o.MetricsBindAddress, err.Error()))
+		}
+	}
+
+	if o.KubeAPIQPS <= 0 {
+		errs = append(errs, field.Invalid(newPath.Child("KubeAPIQPS"), o.KubeAPIQPS, "must be greater than 0"))
+	}
+	if o.KubeAPIBurst <= 0 {
+		errs = append(errs, field.Invalid(newPath.Child("KubeAPIBurst"), o.KubeAPIBurst, "must be greater than 0"))
+	}
+
+	errs = append(errs, o.validateCerts(newPath)...)
+
+	if o.PreCacheResources != nil {
+		errs = append(errs, validatePreCacheResources(o.PreCacheResources, newPath.Child("PreCacheResources"))...)
+	}
+
+	switch o.VerifyMutation {
+	case "", VerifyMutationNone, VerifyMutationWarn, VerifyMutationDeny:
+	default:
+		errs = append(errs, field.NotSupported(newPath.Child("VerifyMutation"), o.VerifyMutation,
+			[]string{VerifyMutationNone, VerifyMutationWarn, VerifyMutationDeny}))
+	}
+
+	errs = append(errs, o.validateTLS(newPath)...)
+
+	if len(o.AllowedClientNames) != 0 && o.ClientCAFile == "" {
+		errs = append(errs, field.Required(newPath.Child("ClientCAFile"), "must be set with AllowedClientNames"))
+	}
+	for i, name := range o.AllowedClientNames {
+		if name == "" {
+			errs = append(errs, field.Invalid(newPath.Child("AllowedClientNames").Index(i), name, "must not be empty"))
+		}
+	}
+
+	if o.DebugTokenFile != "" && o.DebugDelegatedAuth {
+		errs = append(errs, field.Invalid(newPath.Child("DebugTokenFile"), o.DebugTokenFile, "can not be used with DebugDelegatedAuth"))
+	}
+	if o.DebugBindAddress != "" {
+		if _, _, err := net.SplitHostPort(o.DebugBindAddress); err != nil {
+			errs = append(errs, field.Invalid(newPath.Child("DebugBindAddress"), o.DebugBindAddress, err.Error()))
+		}
+	}
+
+	if o.ShutdownDrainPeriod < 0 {
+		errs = append(errs, field.Invalid(newPath.Child("ShutdownDrainPeriod"), o.ShutdownDrainPeriod, "must be greater than or equal to 0"))
+	}
+
+	if o.Config != nil {
+		errs = append(errs, ValidateConfig(o.Config, newPath.Child("Config"))...)
+	}
+
+	return errs
+}
+
+func (o *Options) validateTLS(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if _, err := tlsconfig.Version(o.TLSMinVersion); err != nil {
+		errs = append(errs, field.NotSupported(fldPath.Child("TLSMinVersion"), o.TLSMinVersion, []string{"1.0", "1.1", "1.2", "1.3"}))
+	}
+	if _, _, err := tlsconfig.Profile(o.TLSProfile); err != nil {
+		errs = append(errs, field.NotSupported(fldPath.Child("TLSProfile"), o.TLSProfile, []string{tlsconfig.ProfileFIPS}))
+	}
+	if o.TLSProfile == tlsconfig.ProfileFIPS {
+		if version, err := tlsconfig.Version(o.TLSMinVersion); err == nil && version < tls.VersionTLS12 {
+			errs = append(errs, field.Invalid(fldPath.Child("TLSMinVersion"), o.TLSMinVersion, "must be 1.2 or above with the FIPS profile"))
+		}
+	}
+	for i, name := range o.TLSCipherSuites {
+		if _, err := tlsconfig.CipherSuites([]string{name}); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("TLSCipherSuites").Index(i), name, err.Error()))
+		}
+	}
+	for i, name := range o.TLSCurvePreferences {
+		if _, err := tlsconfig.CurvePreferences([]string{name}); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("TLSCurvePreferences").Index(i), name, err.Error()))
+		}
+	}
+
+	return errs
+}
+
+// validateCerts checks the certificate directory and the options of the cert rotator.
+func (o *Options) validateCerts(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	certDirPath := fldPath.Child("CertDir")
+	if o.CertDir == "" {
+		errs = append(errs, field.Required(certDirPath, ""))
+	} else if info, err := os.Stat(o.CertDir); err == nil && !info.IsDir() {
+		errs = append(errs, field.Invalid(certDirPath, o.CertDir, "not a directory"))
+	} else if !o.SelfSignedCerts {
+		// certificates are not generated, so they must be readable at start up
+		if err != nil {
+			errs = append(errs, field.Invalid(certDirPath, o.CertDir, err.Error()))
+		}
+		for _, name := range []string{"tls.crt", "tls.key"} {
+			if f, err := os.Open(filepath.Join(o.CertDir, name)); err != nil {
+				errs = append(errs, field.Invalid(certDirPath, o.CertDir, err.Error()))
+			} else {
+				_ = f.Close()
+			}
+		}
+	}
+
+	if !o.SelfSignedCerts {
+		return errs
+	}
+
+	certOpts := o.CertOptions()
+	for _, msg := range validation.IsDNS1123Label(certOpts.Namespace) {
+		errs = append(errs, field.Invalid(fldPath.Child("CertNamespace"), certOpts.Namespace, msg))
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(certOpts.SecretName) {
+		errs = append(errs, field.Invalid(fldPath.Child("CertSecretName"), certOpts.SecretName, msg))
+	}
+	for _, msg := range validation.IsDNS1035Label(certOpts.ServiceName) {
+		errs = append(errs, field.Invalid(fldPath.Child("CertServiceName"), certOpts.ServiceName, msg))
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(certOpts.Webhooks[0].Name) {
+		errs = append(errs, field.Invalid(fldPath.Child("CertMutatingConfig"), certOpts.Webhooks[0].Name, msg))
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(certOpts.Webhooks[1].Name) {
+		errs = append(errs, field.Invalid(fldPath.Child("CertValidatingConfig"), certOpts.Webhooks[1].Name, msg))
+	}
+	for i, name := range o.CertExtraDNSNames {
+		msgs := validation.IsDNS1123Subdomain(name)
+		if strings.HasPrefix(name, "*.") {
+			msgs = validation.IsWildcardDNS1123Subdomain(name)
+		}
+		for _, msg := range msgs {
+			errs = append(errs, field.Invalid(fldPath.Child("CertExtraDNSNames").Index(i), name, msg))
+		}
+	}
+
+	if o.CertValidity < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("CertValidity"), o.CertValidity, "must not be negative, 0 uses the default"))
+	}
+	if o.CertLookahead < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("CertLookahead"), o.CertLookahead, "must not be negative, 0 uses the default"))
+	} else if certOpts.Lookahead >= certOpts.Validity {
+		errs = append(errs, field.Invalid(fldPath.Child("CertLookahead"), certOpts.Lookahead, "must be shorter than CertValidity"))
+	}
+
+	for i, target := range o.CertCABundleTargets {
+		webhook, err := cert.ParseWebhookInfo(target)
+		if err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("CertCABundleTargets").Index(i), target, err.Error()))
+			continue
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(webhook.Name) {
+			errs = append(errs, field.Invalid(fldPath.Child("CertCABundleTargets").Index(i), target, msg))
+		}
+	}
+	if _, err := labels.Parse(o.CertCABundleSelector); err != nil {
+		errs = append(errs, field.Invalid(fldPath.Child("CertCABundleSelector"), o.CertCABundleSelector, err.Error()))
+	}
+
+	return errs
+}
+
+// validatePreCacheResources checks duplicate resources. Resource names are resolved to kinds at start up.
+func validatePreCacheResources(resources *ResourceSlice, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	seen := sets.NewString()
+	for i, resource := range resources.GetSlice() {
+		if seen.Has(resource) {
+			errs = append(errs, field.Duplicate(fldPath.Index(i), resource))
+		}
+		seen.Insert(resource)
+	}
+
+	return errs
+}
+
+// ValidateConfig checks the configuration file and return a slice of found errs.
+func ValidateConfig(cfg *configv1alpha1.WebhookConfiguration, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if cfg.APIVersion != configv1alpha1.SchemeGroupVersion.String() {
+		errs = append(errs, field.Invalid(fldPath.Child("apiVersion"), cfg.APIVersion, "must be "+configv1alpha1.SchemeGroupVersion.String()))
+	}
+	if cfg.Kind != configv1alpha1.Kind {
+		errs = append(errs, field.Invalid(fldPath.Child("kind"), cfg.Kind, "must be "+configv1alpha1.Kind))
+	}
+
+	if cfg.Server.SecurePort < 0 || cfg.Server.SecurePort > 65535 {
+		errs = append(errs, field.Invalid(fldPath.Child("server", "securePort"), cfg.Server.SecurePort, "must be a valid port between 0 and 65535 inclusive"))
+	}
+
+	if v := cfg.Logging.Verbosity; v != nil && *v < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("logging", "verbosity"), *v, "must be greater than or equal to 0"))
+	}
+
+	resources := NewPreCacheResources([]string{})
+	for i, r := range cfg.Cache.PreCacheResources {
+		if err := resources.Append(r); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("cache", "preCacheResources").Index(i), r, err.Error()))
+		}
+	}
+
+	errs = append(errs, validateAllowlist(cfg.Allowlist, fldPath.Child("allowlist"))...)
+
+	return errs
+}
+
+func validateAllowlist(allowlist configv1alpha1.AllowlistConfiguration, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	for i, ns := range allowlist.Namespaces {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			errs = append(errs, field.Invalid(fldPath.Child("namespaces").Index(i), ns, msg))
+		}
+	}
+
+	if allowlist.NamespaceSelector != nil {
+		errs = append(errs, metav1validation.ValidateLabelSelector(allowlist.NamespaceSelector, fldPath.Child("namespaceSelector"))...)
+	}
+
+	for i, sa := range allowlist.ServiceAccounts {
+		items := strings.Split(sa, "/")
+		if len(items) != 2 || len(validation.IsDNS1123Label(items[0])) != 0 || items[1] == "" {
+			errs = append(errs, field.Invalid(fldPath.Child("serviceAccounts").Index(i), sa, "must be in namespace/name format, name can be *"))
+		}
+	}
+
+	for i, res := range allowlist.Resources {
+		if _, err := pkgallowlist.ParseResource(res); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("resources").Index(i), res, err.Error()))
+		}
+	}
+
 	return errs
 }
@@ -1,12 +1,29 @@
 package options
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	configv1alpha1 "github.com/k-cloud-labs/kinitiras/pkg/apis/config/v1alpha1"
 )
 
+// withDefaults fills options which are defaulted by flags, but left out of the test cases below.
+func withDefaults(o Options, certDir string) *Options {
+	o.CertDir = certDir
+	o.SelfSignedCerts = true
+	if o.TLSMinVersion == "" {
+		o.TLSMinVersion = "1.3"
+	}
+	return &o
+}
+
 func TestValidateKinitirasWebhookConfiguration(t *testing.T) {
+	certDir := t.TempDir()
 	successCases := []Options{
 		{
 			BindAddress:  "127.0.0.1",
@@ -16,7 +33,7 @@ func TestValidateKinitirasWebhookConfiguration(t *testing.T) {
 		},
 	}
 	for _, successCases := range successCases {
-		if errs := successCases.Validate(); len(errs) != 0 {
+		if errs := withDefaults(successCases, certDir).Validate(); len(errs) != 0 {
 			t.Errorf("expected success: %v", errs)
 		}
 	}
@@ -43,10 +60,139 @@ func TestValidateKinitirasWebhookConfiguration(t *testing.T) {
 			},
 			expectedErrs: field.ErrorList{field.Invalid(newPath.Child("SecurePort"), 900000, "must be a valid port between 0 and 65535 inclusive")},
 		},
+		"invalid MetricsBindAddress": {
+			opt: Options{
+				BindAddress:        "127.0.0.1",
+				SecurePort:         9000,
+				MetricsBindAddress: "8080",
+				KubeAPIQPS:         40,
+				KubeAPIBurst:       30,
+			},
+			expectedErrs: field.ErrorList{field.Invalid(newPath.Child("MetricsBindAddress"), "8080", "address 8080: missing port in address")},
+		},
+		"invalid KubeAPIQPS and KubeAPIBurst": {
+			opt: Options{
+				BindAddress: "127.0.0.1",
+				SecurePort:  9000,
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(newPath.Child("KubeAPIQPS"), float32(0), "must be greater than 0"),
+				field.Invalid(newPath.Child("KubeAPIBurst"), 0, "must be greater than 0"),
+			},
+		},
+		"duplicate PreCacheResources": {
+			opt: Options{
+				BindAddress:       "127.0.0.1",
+				SecurePort:        9000,
+				KubeAPIQPS:        40,
+				KubeAPIBurst:      30,
+				PreCacheResources: NewPreCacheResources([]string{"Pod/v1", "deploy", "Pod/v1"}),
+			},
+			expectedErrs: field.ErrorList{field.Duplicate(newPath.Child("PreCacheResources").Index(1), "Pod/v1")},
+		},
+		"invalid TLSMinVersion": {
+			opt: Options{
+				BindAddress:   "127.0.0.1",
+				SecurePort:    9000,
+				KubeAPIQPS:    40,
+				KubeAPIBurst:  30,
+				TLSMinVersion: "1.4",
+			},
+			expectedErrs: field.ErrorList{field.NotSupported(newPath.Child("TLSMinVersion"), "1.4", []string{"1.0", "1.1", "1.2", "1.3"})},
+		},
+		"invalid VerifyMutation": {
+			opt: Options{
+				BindAddress:    "127.0.0.1",
+				SecurePort:     9000,
+				KubeAPIQPS:     40,
+				KubeAPIBurst:   30,
+				VerifyMutation: "Reject",
+			},
+			expectedErrs: field.ErrorList{field.NotSupported(newPath.Child("VerifyMutation"), "Reject", []string{"None", "Warn", "Deny"})},
+		},
+		"invalid TLSCipherSuites": {
+			opt: Options{
+				BindAddress:     "127.0.0.1",
+				SecurePort:      9000,
+				KubeAPIQPS:      40,
+				KubeAPIBurst:    30,
+				TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_RC4_128_SHA"},
+			},
+			expectedErrs: field.ErrorList{field.Invalid(newPath.Child("TLSCipherSuites").Index(1), "TLS_RSA_WITH_RC4_128_SHA",
+				`unsupported cipher suite "TLS_RSA_WITH_RC4_128_SHA"`)},
+		},
+		"FIPS TLSProfile with TLSMinVersion 1.1": {
+			opt: Options{
+				BindAddress:   "127.0.0.1",
+				SecurePort:    9000,
+				KubeAPIQPS:    40,
+				KubeAPIBurst:  30,
+				TLSMinVersion: "1.1",
+				TLSProfile:    "FIPS",
+			},
+			expectedErrs: field.ErrorList{field.Invalid(newPath.Child("TLSMinVersion"), "1.1", "must be 1.2 or above with the FIPS profile")},
+		},
+		"AllowedClientNames without ClientCAFile": {
+			opt: Options{
+				BindAddress:        "127.0.0.1",
+				SecurePort:         9000,
+				KubeAPIQPS:         40,
+				KubeAPIBurst:       30,
+				AllowedClientNames: []string{"kube-apiserver-webhook-client"},
+			},
+			expectedErrs: field.ErrorList{field.Required(newPath.Child("ClientCAFile"), "must be set with AllowedClientNames")},
+		},
+		"DebugTokenFile with DebugDelegatedAuth": {
+			opt: Options{
+				BindAddress:        "127.0.0.1",
+				SecurePort:         9000,
+				KubeAPIQPS:         40,
+				KubeAPIBurst:       30,
+				DebugTokenFile:     "/etc/kinitiras/token",
+				DebugDelegatedAuth: true,
+			},
+			expectedErrs: field.ErrorList{field.Invalid(newPath.Child("DebugTokenFile"), "/etc/kinitiras/token", "can not be used with DebugDelegatedAuth")},
+		},
+		"invalid DebugBindAddress": {
+			opt: Options{
+				BindAddress:      "127.0.0.1",
+				SecurePort:       9000,
+				KubeAPIQPS:       40,
+				KubeAPIBurst:     30,
+				DebugBindAddress: "127.0.0.1",
+			},
+			expectedErrs: field.ErrorList{field.Invalid(newPath.Child("DebugBindAddress"), "127.0.0.1", "address 127.0.0.1: missing port in address")},
+		},
+		"invalid ShutdownDrainPeriod": {
+			opt: Options{
+				BindAddress:         "127.0.0.1",
+				SecurePort:          9000,
+				KubeAPIQPS:          40,
+				KubeAPIBurst:        30,
+				ShutdownDrainPeriod: -time.Second,
+			},
+			expectedErrs: field.ErrorList{field.Invalid(newPath.Child("ShutdownDrainPeriod"), -time.Second, "must be greater than or equal to 0")},
+		},
+		"invalid Config": {
+			opt: Options{
+				BindAddress:  "127.0.0.1",
+				SecurePort:   9000,
+				KubeAPIQPS:   40,
+				KubeAPIBurst: 30,
+				Config: &configv1alpha1.WebhookConfiguration{
+					TypeMeta: metav1.TypeMeta{APIVersion: "config.kcloudlabs.io/v1alpha1", Kind: "Config"},
+					Cache:    configv1alpha1.CacheConfiguration{PreCacheResources: []string{"Pod/"}},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(newPath.Child("Config", "kind"), "Config", "must be WebhookConfiguration"),
+				field.Invalid(newPath.Child("Config", "cache", "preCacheResources").Index(0), "Pod/", "invalid gvk(Pod/)"),
+			},
+		},
 	}
 
 	for _, testCase := range testCases {
-		errs := testCase.opt.Validate()
+		errs := withDefaults(testCase.opt, certDir).Validate()
 		if len(testCase.expectedErrs) != len(errs) {
 			t.Fatalf("Expected %d errors, got %d errors: %v", len(testCase.expectedErrs), len(errs), errs)
 		}
@@ -57,3 +203,59 @@ func TestValidateKinitirasWebhookConfiguration(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateCerts(t *testing.T) {
+	newPath := field.NewPath("Options")
+	certDir := t.TempDir()
+	missing := filepath.Join(certDir, "missing")
+
+	opts := withDefaults(Options{BindAddress: "127.0.0.1", SecurePort: 9000, KubeAPIQPS: 40, KubeAPIBurst: 30}, missing)
+	if errs := opts.Validate(); len(errs) != 0 {
+		t.Errorf("expected success for self-signed certs in a new directory: %v", errs)
+	}
+
+	opts.SelfSignedCerts = false
+	opts.CertDir = certDir
+	if err := os.WriteFile(filepath.Join(certDir, "tls.crt"), []byte("cert"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	errs := opts.Validate()
+	if len(errs) != 1 || errs[0].Field != newPath.Child("CertDir").String() {
+		t.Errorf("expected error of missing tls.key, got %v", errs)
+	}
+
+	opts.SelfSignedCerts = true
+	opts.CertNamespace = "Kinitiras_System"
+	errs = opts.Validate()
+	if len(errs) != 1 || errs[0].Field != newPath.Child("CertNamespace").String() {
+		t.Errorf("expected error of invalid CertNamespace, got %v", errs)
+	}
+
+	opts.CertNamespace = ""
+	opts.CertValidity = 24 * time.Hour
+	opts.CertLookahead = 48 * time.Hour
+	opts.CertExtraDNSNames = []string{"webhook.example.com", "*.example.com", "webhook_example"}
+	opts.CertCABundleTargets = []string{"CustomResourceDefinition/foos.example.com", "Deployment/foo"}
+	errs = opts.Validate()
+	if len(errs) != 3 ||
+		errs[0].Field != newPath.Child("CertExtraDNSNames").Index(2).String() ||
+		errs[1].Field != newPath.Child("CertLookahead").String() ||
+		errs[2].Field != newPath.Child("CertCABundleTargets").Index(1).String() {
+		t.Errorf("expected errors of CertExtraDNSNames, CertLookahead and CertCABundleTargets, got %v", errs)
+	}
+
+	opts.CertValidity, opts.CertLookahead, opts.CertExtraDNSNames, opts.CertCABundleTargets = 0, 0, nil, nil
+	opts.CertCABundleSelector = "app in (kinitiras"
+	errs = opts.Validate()
+	if len(errs) != 1 || errs[0].Field != newPath.Child("CertCABundleSelector").String() {
+		t.Errorf("expected error of invalid CertCABundleSelector, got %v", errs)
+	}
+
+	// 0 uses the defaults, negative durations are rejected
+	opts.CertCABundleSelector = ""
+	opts.CertValidity, opts.CertLookahead = -time.Hour, 0
+	errs = opts.Validate()
+	if len(errs) != 1 || errs[0].Field != newPath.Child("CertValidity").String() {
+		t.Errorf("expected error of negative CertValidity, got %v", errs)
+	}
+}
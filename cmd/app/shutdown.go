@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// shutdownReadiness fails readiness checks once the graceful shutdown starts, so the webhook is
+// removed from endpoints of its service while it still serves admission requests.
+type shutdownReadiness struct {
+	shuttingDown int32
+}
+
+// Check implements healthz.Checker.
+func (r *shutdownReadiness) Check(_ *http.Request) error {
+	if atomic.LoadInt32(&r.shuttingDown) != 0 {
+		return errors.New("shutting down")
+	}
+	return nil
+}
+
+// gracefulShutdown returns a context which is canceled a drain period after ctx is done. The manager, informers
+// and listeners run with the returned context, so in-flight and late admission requests still succeed while
+// readiness fails during the period. Listeners wait for in-flight requests when they are closed.
+func gracefulShutdown(ctx context.Context, readiness *shutdownReadiness, drainPeriod time.Duration) context.Context {
+	runCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+		<-ctx.Done()
+
+		atomic.StoreInt32(&readiness.shuttingDown, 1)
+		klog.InfoS("shutting down, draining admission requests.", "drainPeriod", drainPeriod)
+		time.Sleep(drainPeriod)
+		klog.InfoS("drain period is over, stopping webhook server.")
+	}()
+
+	return runCtx
+}
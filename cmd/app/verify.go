@@ -0,0 +1,273 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+
+	policyv1alpha1 "github.com/k-cloud-labs/pkg/apis/policy/v1alpha1"
+	"github.com/k-cloud-labs/pkg/utils/interrupter"
+	"github.com/k-cloud-labs/pkg/utils/tokenmanager"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/lister"
+	"github.com/k-cloud-labs/kinitiras/pkg/precache"
+	pkgwebhook "github.com/k-cloud-labs/kinitiras/pkg/webhook"
+)
+
+type verifyOptions struct {
+	filename        string
+	oldFilename     string
+	policies        []string
+	operation       string
+	namespace       string
+	namespaceLabels map[string]string
+	username        string
+	groups          []string
+	idempotency     bool
+}
+
+// NewVerifyCommand creates a command which applies override policies to an object and then verifies the
+// mutated object against validate policies, the same as the mutating webhook with --verify-mutation=Deny
+// and --check-idempotency.
+// It runs without a cluster, so policies referring resources from cluster are not supported.
+func NewVerifyCommand(out io.Writer) *cobra.Command {
+	o := &verifyOptions{}
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify objects mutated by override policies satisfy validate policies",
+		Long: `Apply override policies to an object and validate the mutated object by validate policies without a cluster.
+The mutated object is printed, and the command fails if any validate policy rejects it.`,
+		Example: `  kinitiras-webhook verify -f deployment.yaml -p policies/`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd.Context(), out)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&o.filename, "filename", "f", "", "The file of the object to verify.")
+	flags.StringVar(&o.oldFilename, "old-filename", "", "The file of the old object, required by UPDATE operation.")
+	flags.StringSliceVarP(&o.policies, "policies", "p", nil, "Files or directories of OverridePolicies, ClusterOverridePolicies and ClusterValidatePolicies.")
+	flags.StringVar(&o.operation, "operation", string(admissionv1.Create), "The operation of the admission request. Possible values: CREATE, UPDATE.")
+	flags.StringVarP(&o.namespace, "namespace", "n", "", "The namespace of the object, default to the namespace in the object file.")
+	flags.StringToStringVar(&o.namespaceLabels, "namespace-labels", nil, "Labels of the namespace used by namespace selectors of policies.")
+	flags.StringVar(&o.username, "username", "", "The user sending the request, used by user selectors of policies.")
+	flags.StringSliceVar(&o.groups, "groups", nil, "Groups of the user sending the request.")
+	flags.BoolVar(&o.idempotency, "check-idempotency", true, "Fail if an override policy changes the object again when applied twice.")
+	_ = cmd.MarkFlagRequired("filename")
+	_ = cmd.MarkFlagRequired("policies")
+
+	return cmd
+}
+
+func (o *verifyOptions) run(ctx context.Context, out io.Writer) error {
+	operation := admissionv1.Operation(o.operation)
+	if operation != admissionv1.Create && operation != admissionv1.Update {
+		return fmt.Errorf("unsupported operation %q", o.operation)
+	}
+
+	obj, err := readObject(o.filename)
+	if err != nil {
+		return err
+	}
+	var oldObj *unstructured.Unstructured
+	if operation == admissionv1.Update {
+		if o.oldFilename == "" {
+			return errors.New("--old-filename is required by UPDATE operation")
+		}
+		if oldObj, err = readObject(o.oldFilename); err != nil {
+			return err
+		}
+	}
+	if o.namespace != "" {
+		obj.SetNamespace(o.namespace)
+	}
+
+	managers, err := o.policyManagers()
+	if err != nil {
+		return err
+	}
+
+	gvk := obj.GroupVersionKind()
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+		Operation: operation,
+		UserInfo:  authenticationv1.UserInfo{Username: o.username, Groups: o.groups},
+	}}
+
+	evaluation, err := managers.Evaluate(ctx, req, obj, oldObj, o.idempotency)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(evaluation.Object.Object)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s", data)
+	fmt.Fprintf(os.Stderr, "applied override policies: %s\n", strings.Join(evaluation.Applied, ", "))
+	for _, conflict := range evaluation.Conflicts {
+		fmt.Fprintf(os.Stderr, "conflict: %s written by %s\n", conflict.Path, strings.Join(conflict.Policies, ", "))
+	}
+
+	if len(evaluation.NonIdempotent) != 0 {
+		return fmt.Errorf("override policies are not idempotent: %s", strings.Join(evaluation.NonIdempotent, ", "))
+	}
+	if len(evaluation.Violations) != 0 {
+		return errors.New(pkgwebhook.ViolationMessage(evaluation.Applied, evaluation.Violations))
+	}
+
+	return nil
+}
+
+// policyManagers builds policy managers from policies in files, after their templates are rendered by interrupters.
+func (o *verifyOptions) policyManagers() (*pkgwebhook.PolicyManagers, error) {
+	newIndexer := func() cache.Indexer {
+		return cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	}
+	opIndexer, copIndexer, cvpIndexer := newIndexer(), newIndexer(), newIndexer()
+	opLister := lister.NewUnstructuredOverridePolicyLister(opIndexer)
+	copLister := lister.NewUnstructuredClusterOverridePolicyLister(copIndexer)
+	cvpLister := lister.NewUnstructuredClusterValidatePolicyLister(cvpIndexer)
+
+	// policies are rendered by interrupters the same as on admission, there is no client since policies referring
+	// resources from cluster are not supported
+	pim := interrupter.NewPolicyInterrupterManager()
+	if err := addPolicyInterrupters(pim, tokenmanager.NewTokenManager(), nil, opLister, copLister, cvpLister); err != nil {
+		return nil, err
+	}
+	if err := pim.OnStartUp(); err != nil {
+		return nil, err
+	}
+
+	files, err := policyFiles(o.policies)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		policies, err := readObjects(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, policy := range policies {
+			if policy.GroupVersionKind().Group != policyv1alpha1.SchemeGroupVersion.Group {
+				continue
+			}
+			if refs := precache.ReferencedResources(policy); len(refs) != 0 {
+				return nil, fmt.Errorf("%s %s refers resources from cluster, which is not supported by verify", policy.GetKind(), policy.GetName())
+			}
+			if err := pim.OnValidating(policy, nil, admissionv1.Create); err != nil {
+				return nil, fmt.Errorf("invalid %s %s: %w", policy.GetKind(), policy.GetName(), err)
+			}
+			if _, err := pim.OnMutating(policy, nil, admissionv1.Create); err != nil {
+				return nil, fmt.Errorf("failed to render %s %s: %w", policy.GetKind(), policy.GetName(), err)
+			}
+
+			switch policy.GetKind() {
+			case "OverridePolicy":
+				err = opIndexer.Add(policy)
+			case "ClusterOverridePolicy":
+				err = copIndexer.Add(policy)
+			case "ClusterValidatePolicy":
+				err = cvpIndexer.Add(policy)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &pkgwebhook.PolicyManagers{
+		OverridePolicyLister:        opLister,
+		ClusterOverridePolicyLister: copLister,
+		ClusterValidatePolicyLister: cvpLister,
+		NamespaceLabels: func(namespace string) (map[string]string, bool) {
+			return o.namespaceLabels, true
+		},
+	}, nil
+}
+
+// policyFiles returns the yaml and json files in paths, directories are not walked recursively.
+func policyFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+	}
+
+	return files, nil
+}
+
+func readObject(file string) (*unstructured.Unstructured, error) {
+	objs, err := readObjects(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) != 1 {
+		return nil, fmt.Errorf("expect one object in %s, got %d", file, len(objs))
+	}
+
+	return objs[0], nil
+}
+
+// readObjects reads objects from a yaml file with multiple documents or a json file.
+func readObjects(file string) ([]*unstructured.Unstructured, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var objs []*unstructured.Unstructured
+	decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+		}
+		if len(obj.Object) != 0 {
+			objs = append(objs, obj)
+		}
+	}
+
+	return objs, nil
+}
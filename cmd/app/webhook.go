@@ -7,12 +7,21 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/spf13/cobra"
 	"go.etcd.io/etcd/pkg/v3/debugutil"
 	"golang.org/x/sync/errgroup"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 	controllerruntime "sigs.k8s.io/controller-runtime"
@@ -27,19 +36,45 @@ import (
 	"github.com/k-cloud-labs/pkg/utils/informermanager"
 	"github.com/k-cloud-labs/pkg/utils/interrupter"
 	"github.com/k-cloud-labs/pkg/utils/metrics"
-	"github.com/k-cloud-labs/pkg/utils/overridemanager"
 	"github.com/k-cloud-labs/pkg/utils/templatemanager"
 	"github.com/k-cloud-labs/pkg/utils/templatemanager/templates"
 	"github.com/k-cloud-labs/pkg/utils/tokenmanager"
-	"github.com/k-cloud-labs/pkg/utils/validatemanager"
 
 	"github.com/k-cloud-labs/kinitiras/cmd/app/options"
+	"github.com/k-cloud-labs/kinitiras/pkg/allowlist"
+	"github.com/k-cloud-labs/kinitiras/pkg/auth"
 	"github.com/k-cloud-labs/kinitiras/pkg/controller/cert"
+	"github.com/k-cloud-labs/kinitiras/pkg/controller/deletehook"
+	"github.com/k-cloud-labs/kinitiras/pkg/controller/webhookconfig"
+	"github.com/k-cloud-labs/kinitiras/pkg/debug"
 	"github.com/k-cloud-labs/kinitiras/pkg/lister"
+	"github.com/k-cloud-labs/kinitiras/pkg/metricsserver"
+	"github.com/k-cloud-labs/kinitiras/pkg/precache"
 	"github.com/k-cloud-labs/kinitiras/pkg/util/gclient"
+	"github.com/k-cloud-labs/kinitiras/pkg/util/tlsconfig"
 	"github.com/k-cloud-labs/kinitiras/pkg/version"
 	"github.com/k-cloud-labs/kinitiras/pkg/version/sharedcommand"
 	pkgwebhook "github.com/k-cloud-labs/kinitiras/pkg/webhook"
+	"github.com/k-cloud-labs/kinitiras/pkg/webhookserver"
+)
+
+var (
+	opGVR = schema.GroupVersionResource{
+		Group:    policyv1alpha1.SchemeGroupVersion.Group,
+		Version:  policyv1alpha1.SchemeGroupVersion.Version,
+		Resource: "overridepolicies",
+	}
+	copGVR = schema.GroupVersionResource{
+		Group:    policyv1alpha1.SchemeGroupVersion.Group,
+		Version:  policyv1alpha1.SchemeGroupVersion.Version,
+		Resource: "clusteroverridepolicies",
+	}
+	cvpGVR = schema.GroupVersionResource{
+		Group:    policyv1alpha1.SchemeGroupVersion.Group,
+		Version:  policyv1alpha1.SchemeGroupVersion.Version,
+		Resource: "clustervalidatepolicies",
+	}
+	nsGVR = corev1.SchemeGroupVersion.WithResource("namespaces")
 )
 
 // NewWebhookCommand creates a *cobra.Command object with default parameters
@@ -52,6 +87,11 @@ func NewWebhookCommand(ctx context.Context) *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			options.PrintFlags(cmd.Flags())
 
+			// load config file
+			if err := opts.Complete(); err != nil {
+				return err
+			}
+
 			// validate options
 			if errs := opts.Validate(); len(errs) != 0 {
 				return errs.ToAggregate()
@@ -73,6 +113,7 @@ func NewWebhookCommand(ctx context.Context) *cobra.Command {
 
 	cmd.Flags().AddGoFlagSet(flag.CommandLine)
 	cmd.AddCommand(sharedcommand.NewCmdVersion(os.Stdout, "kinitiras-webhook"))
+	cmd.AddCommand(NewVerifyCommand(os.Stdout))
 	opts.AddFlags(cmd.Flags())
 
 	return cmd
@@ -87,15 +128,25 @@ func Run(ctx context.Context, opts *options.Options) error {
 	}
 	config.QPS, config.Burst = opts.KubeAPIQPS, opts.KubeAPIBurst
 
+	metricsBindAddress := opts.MetricsBindAddress
+	if opts.SecureMetrics {
+		// metrics are served by the secure metrics server instead
+		metricsBindAddress = "0"
+	}
+	tlsConfig, err := opts.TLSConfig()
+	if err != nil {
+		klog.ErrorS(err, "invalid TLS options.")
+		return err
+	}
+	// the webhook server is added to the manager once certificates are ready
+	hookServer := webhookserver.New(&webhook.Server{
+		Host:    opts.BindAddress,
+		Port:    opts.SecurePort,
+		CertDir: opts.CertDir,
+	}, webhookServerOptions(opts, tlsConfig))
 	hookManager, err := controllerruntime.NewManager(config, controllerruntime.Options{
-		Scheme: gclient.NewSchema(),
-		WebhookServer: &webhook.Server{
-			Host:          opts.BindAddress,
-			Port:          opts.SecurePort,
-			CertDir:       opts.CertDir,
-			TLSMinVersion: opts.TLSMinVersion,
-		},
-		MetricsBindAddress: opts.MetricsBindAddress,
+		Scheme:             gclient.NewSchema(),
+		MetricsBindAddress: metricsBindAddress,
 		LeaderElection:     false,
 	})
 	if err != nil {
@@ -108,13 +159,9 @@ func Run(ctx context.Context, opts *options.Options) error {
 		return err
 	}
 
-	// pprof
-	for s, handler := range debugutil.PProfHandlers() {
-		if err = hookManager.AddMetricsExtraHandler(s, handler); err != nil {
-			klog.ErrorS(err, "failed to add pprof handler.")
-			return err
-		}
-	}
+	// the signal only starts the graceful shutdown, the manager and informers stop after the drain period
+	readiness := &shutdownReadiness{}
+	ctx = gracefulShutdown(ctx, readiness, opts.ShutdownDrainPeriod)
 
 	sm := &setupManager{
 		opts: opts,
@@ -129,42 +176,80 @@ func Run(ctx context.Context, opts *options.Options) error {
 		return err
 	}
 
+	if opts.SecureMetrics {
+		if err := sm.setupSecureMetrics(); err != nil {
+			klog.ErrorS(err, "failed to setup secure metrics server.")
+			return err
+		}
+	}
+
+	if err := sm.setupDebugHandlers(); err != nil {
+		klog.ErrorS(err, "failed to setup debug handlers.")
+		return err
+	}
+
 	if err := sm.setupInterrupter(); err != nil {
 		klog.ErrorS(err, "setup interrupter failed")
 		return err
 	}
 
-	setupCh, err := cert.SetupCertRotator(hookManager, cert.Options{
-		Namespace:      os.Getenv("NAMESPACE"),
-		SecretName:     os.Getenv("SECRET"),
-		CAOrganization: os.Getenv("CA_ORGANIZATION"),
-		CAName:         os.Getenv("CA_NAME"),
-		ServiceName:    os.Getenv("SERVICE_NAME"),
-		CertDir:        opts.CertDir,
-		Webhooks: []cert.WebhookInfo{
-			{
-				Name: os.Getenv("MUTATING_CONFIG"),
-				Type: cert.Mutating,
-			},
-			{
-				Name: os.Getenv("VALIDATING_CONFIG"),
-				Type: cert.Validating,
-			},
-		},
-	})
-	if err != nil {
-		klog.ErrorS(err, "failed to setup cert rotator controller.")
-		return err
+	certOpts := opts.CertOptions()
+
+	if opts.EnableDeleteHooks {
+		if err := sm.setupDeleteHookController(); err != nil {
+			klog.ErrorS(err, "failed to setup delete hook controller.")
+			return err
+		}
+	}
+
+	if opts.ManageWebhookRules {
+		if err := sm.setupWebhookConfigController(certOpts.Webhooks); err != nil {
+			klog.ErrorS(err, "failed to setup webhook configuration controller.")
+			return err
+		}
+	}
+
+	setupCh := make(chan struct{})
+	if opts.SelfSignedCerts {
+		if setupCh, err = cert.SetupCertRotator(hookManager, certOpts); err != nil {
+			klog.ErrorS(err, "failed to setup cert rotator controller.")
+			return err
+		}
+	} else {
+		// certificates are provided by others, e.g. cert-manager
+		close(setupCh)
+	}
+
+	// the watcher starts once everything it reloads is set up
+	if opts.ConfigFile != "" {
+		go sm.watchConfigFile(ctx)
 	}
 
 	go func() {
 		<-setupCh
 
 		klog.InfoS("registering webhooks to the webhook server.")
-		hookServer := hookManager.GetWebhookServer()
-		hookServer.Register("/mutate", &webhook.Admission{Handler: pkgwebhook.NewMutatingAdmissionHandler(sm.overrideManager, sm.policyInterrupterManager)})
-		hookServer.Register("/validate", &webhook.Admission{Handler: pkgwebhook.NewValidatingAdmissionHandler(sm.validateManager, sm.policyInterrupterManager)})
-		hookServer.WebhookMux.Handle("/readyz", http.StripPrefix("/readyz", &healthz.Handler{}))
+		hookServer.Register("/mutate", &webhook.Admission{Handler: pkgwebhook.NewMutatingAdmissionHandler(sm.policyManagers.OverrideManagers,
+			sm.policyManagers.ValidateManagers, pkgwebhook.MutatingOptions{
+				VerifyMode:             pkgwebhook.VerifyMode(opts.VerifyMutation),
+				StampAppliedGeneration: opts.StampAppliedGeneration,
+				CheckIdempotency:       opts.CheckIdempotency,
+				DeleteHook:             sm.deleteHook,
+			}, sm.policyInterrupterManager, sm.allowlist)})
+		hookServer.Register("/validate", &webhook.Admission{Handler: pkgwebhook.NewValidatingAdmissionHandler(sm.policyManagers.ValidateManager,
+			sm.deleteHook, sm.policyInterrupterManager, sm.allowlist)})
+		// sub-checks are served under the paths, e.g. /readyz/informers
+		readyz := http.StripPrefix("/readyz", &healthz.Handler{Checks: sm.readyzChecks(readiness)})
+		healthzHandler := http.StripPrefix("/healthz", &healthz.Handler{Checks: map[string]healthz.Checker{"ping": healthz.Ping}})
+		hookServer.WebhookMux.Handle("/readyz", readyz)
+		hookServer.WebhookMux.Handle("/readyz/", readyz)
+		hookServer.WebhookMux.Handle("/healthz", healthzHandler)
+		hookServer.WebhookMux.Handle("/healthz/", healthzHandler)
+
+		// webhooks registered before are injected by the manager
+		if err := hookManager.Add(hookServer); err != nil {
+			klog.ErrorS(err, "failed to add webhook server.")
+		}
 	}()
 
 	// blocks until the context is done.
@@ -177,8 +262,22 @@ func Run(ctx context.Context, opts *options.Options) error {
 	return nil
 }
 
+// webhookServerOptions returns options of the webhook server, which verifies client certificates of requests
+// to the webhooks if --client-ca-file is set.
+func webhookServerOptions(opts *options.Options, tlsConfig tlsconfig.Config) webhookserver.Options {
+	serverOpts := webhookserver.Options{TLS: tlsConfig}
+	if opts.ClientCAFile != "" {
+		serverOpts.ClientCAFile = opts.ClientCAFile
+		serverOpts.AllowedClientNames = opts.AllowedClientNames
+		serverOpts.ProtectedPaths = []string{"/mutate", "/validate"}
+	}
+	return serverOpts
+}
+
 type setupManager struct {
-	opts                     *options.Options
+	opts *options.Options
+	// optsMu guards options reloaded from the configuration file
+	optsMu                   sync.RWMutex
 	hookManager              manager.Manager
 	done                     <-chan struct{}
 	client                   client.Client
@@ -187,10 +286,19 @@ type setupManager struct {
 	copLister                v1alpha1.ClusterOverridePolicyLister
 	cvpLister                v1alpha1.ClusterValidatePolicyLister
 	informerManager          informermanager.SingleClusterInformerManager
-	overrideManager          overridemanager.OverrideManager
-	validateManager          validatemanager.ValidateManager
+	policyManagers           *pkgwebhook.PolicyManagers
 	policyInterrupterManager interrupter.PolicyInterrupterManager
 	tokenManager             tokenmanager.TokenManager
+	allowlist                *allowlist.Allowlist
+	preCacheTracker          *precache.Tracker
+	restMapper               meta.RESTMapper
+	webhookConfigController  *webhookconfig.Controller
+	deleteHook               pkgwebhook.DeleteHookFunc
+	metricsServer            *metricsserver.Server
+	// interrupterReady is set to 1 once policy interrupters have started up
+	interrupterReady int32
+	// preCacheReady is set to 1 once the cache of pre-cache resources has synced
+	preCacheReady int32
 }
 
 func (s *setupManager) init(hm manager.Manager, done <-chan struct{}) (err error) {
@@ -200,12 +308,22 @@ func (s *setupManager) init(hm manager.Manager, done <-chan struct{}) (err error
 	s.client = hm.GetClient()
 	s.policyInterrupterManager = interrupter.NewPolicyInterrupterManager()
 	s.tokenManager = tokenmanager.NewTokenManager()
+	s.allowlist = allowlist.New(s.opts.Allowlist, s.namespaceLabels)
 
 	s.drLister, err = dynamiclister.NewDynamicResourceLister(hm.GetConfig(), done)
 	if err != nil {
 		klog.ErrorS(err, "failed to init dynamic client.")
 		return err
 	}
+	s.restMapper = restmapper.NewShortcutExpander(hm.GetRESTMapper(), discovery.NewDiscoveryClientForConfigOrDie(hm.GetConfig()))
+	if err = s.opts.PreCacheResources.Resolve(s.restMapper); err != nil {
+		klog.ErrorS(err, "failed to resolve pre-cache resources.")
+		return err
+	}
+
+	// pre cached resources are registered in waitForCacheSync
+	s.preCacheTracker = precache.NewTracker(s.drLister, s.opts.PreCacheResourcesToGVKList()...)
+	s.policyManagers = &pkgwebhook.PolicyManagers{DynamicLister: s.drLister, NamespaceLabels: s.namespaceLabels, DeleteHooks: s.opts.EnableDeleteHooks}
 
 	return nil
 }
@@ -214,11 +332,23 @@ func (s *setupManager) waitForCacheSync(ctx context.Context) error {
 	eg, _ := errgroup.WithContext(ctx)
 	eg.Go(func() error {
 		// pre cached resources
-		err := s.drLister.RegisterNewResource(true, s.opts.PreCacheResourcesToGVKList()...)
+		s.optsMu.RLock()
+		gvks := s.opts.PreCacheResourcesToGVKList()
+		s.optsMu.RUnlock()
+		err := s.drLister.RegisterNewResource(true, gvks...)
 		if err != nil {
 			klog.ErrorS(err, "failed to register resource to lister")
+			return err
 		}
-		return err
+		atomic.StoreInt32(&s.preCacheReady, 1)
+		return nil
+	})
+	eg.Go(func() error {
+		if err := s.setupNamespaceInformer(); err != nil {
+			klog.ErrorS(err, "failed to setup namespace informer.")
+			return err
+		}
+		return nil
 	})
 	eg.Go(func() error {
 		if err := s.setupOverridePolicyManager(); err != nil {
@@ -242,6 +372,22 @@ func (s *setupManager) waitForCacheSync(ctx context.Context) error {
 }
 
 func (s *setupManager) setupInterrupter() error {
+	if err := addPolicyInterrupters(s.policyInterrupterManager, s.tokenManager, s.client, s.opLister, s.copLister, s.cvpLister); err != nil {
+		return err
+	}
+
+	if err := s.policyInterrupterManager.OnStartUp(); err != nil {
+		return err
+	}
+
+	atomic.StoreInt32(&s.interrupterReady, 1)
+	return nil
+}
+
+// addPolicyInterrupters adds the interrupters of OverridePolicy, ClusterOverridePolicy and ClusterValidatePolicy
+// to pim, which render templates of policies.
+func addPolicyInterrupters(pim interrupter.PolicyInterrupterManager, tokenManager tokenmanager.TokenManager, c client.Client,
+	opLister v1alpha1.OverridePolicyLister, copLister v1alpha1.ClusterOverridePolicyLister, cvpLister v1alpha1.ClusterValidatePolicyLister) error {
 	otm, err := templatemanager.NewOverrideTemplateManager(&templatemanager.TemplateSource{
 		Content:      templates.OverrideTemplate,
 		TemplateName: "BaseTemplate",
@@ -264,39 +410,196 @@ func (s *setupManager) setupInterrupter() error {
 	baseInterrupter := interrupter.NewBaseInterrupter(otm, vtm, templatemanager.NewCueManager())
 
 	// op
-	overridePolicyInterrupter := interrupter.NewOverridePolicyInterrupter(baseInterrupter, s.tokenManager, s.client, s.opLister)
-	s.policyInterrupterManager.AddInterrupter(schema.GroupVersionKind{
+	overridePolicyInterrupter := interrupter.NewOverridePolicyInterrupter(baseInterrupter, tokenManager, c, opLister)
+	pim.AddInterrupter(schema.GroupVersionKind{
 		Group:   policyv1alpha1.SchemeGroupVersion.Group,
 		Version: policyv1alpha1.SchemeGroupVersion.Version,
 		Kind:    "OverridePolicy",
 	}, overridePolicyInterrupter)
 	// cop
-	s.policyInterrupterManager.AddInterrupter(schema.GroupVersionKind{
+	pim.AddInterrupter(schema.GroupVersionKind{
 		Group:   policyv1alpha1.SchemeGroupVersion.Group,
 		Version: policyv1alpha1.SchemeGroupVersion.Version,
 		Kind:    "ClusterOverridePolicy",
-	}, interrupter.NewClusterOverridePolicyInterrupter(overridePolicyInterrupter, s.copLister))
+	}, interrupter.NewClusterOverridePolicyInterrupter(overridePolicyInterrupter, copLister))
 	// cvp
-	s.policyInterrupterManager.AddInterrupter(schema.GroupVersionKind{
+	pim.AddInterrupter(schema.GroupVersionKind{
 		Group:   policyv1alpha1.SchemeGroupVersion.Group,
 		Version: policyv1alpha1.SchemeGroupVersion.Version,
 		Kind:    "ClusterValidatePolicy",
-	}, interrupter.NewClusterValidatePolicyInterrupter(baseInterrupter, s.tokenManager, s.client, s.cvpLister))
+	}, interrupter.NewClusterValidatePolicyInterrupter(baseInterrupter, tokenManager, c, cvpLister))
 
-	return s.policyInterrupterManager.OnStartUp()
+	return nil
 }
 
-func (s *setupManager) setupOverridePolicyManager() (err error) {
-	opGVR := schema.GroupVersionResource{
-		Group:    policyv1alpha1.SchemeGroupVersion.Group,
-		Version:  policyv1alpha1.SchemeGroupVersion.Version,
-		Resource: "overridepolicies",
+func (s *setupManager) setupWebhookConfigController(webhooks []cert.WebhookInfo) error {
+	opts := webhookconfig.Options{
+		OverridePolicies: []cache.Store{s.informerManager.Informer(opGVR).GetStore(), s.informerManager.Informer(copGVR).GetStore()},
+		ValidatePolicies: []cache.Store{s.informerManager.Informer(cvpGVR).GetStore()},
 	}
-	copGVR := schema.GroupVersionResource{
-		Group:    policyv1alpha1.SchemeGroupVersion.Group,
-		Version:  policyv1alpha1.SchemeGroupVersion.Version,
-		Resource: "clusteroverridepolicies",
+	s.optsMu.RLock()
+	opts.NamespaceSelector = allowlist.WebhookNamespaceSelector(s.opts.Allowlist)
+	s.optsMu.RUnlock()
+	// the first ones are the configurations of kinitiras, others are only injected with the CA
+	for _, wh := range webhooks {
+		switch {
+		case wh.Type == cert.Mutating && opts.MutatingConfig == "":
+			opts.MutatingConfig = wh.Name
+		case wh.Type == cert.Validating && opts.ValidatingConfig == "":
+			opts.ValidatingConfig = wh.Name
+		}
+	}
+
+	controller := webhookconfig.NewController(s.client, s.restMapper, opts)
+	for _, gvr := range []schema.GroupVersionResource{opGVR, copGVR, cvpGVR} {
+		s.informerManager.Informer(gvr).AddEventHandler(controller.EventHandler())
+	}
+	// the controller is read by reloadConfig
+	s.optsMu.Lock()
+	s.webhookConfigController = controller
+	s.optsMu.Unlock()
+
+	return s.hookManager.Add(controller)
+}
+
+// setupDebugHandlers serves pprof and policy debug handlers if they are enabled, on the metrics server
+// or on the debug bind address.
+func (s *setupManager) setupDebugHandlers() error {
+	handlers := map[string]http.Handler{}
+	if s.opts.EnablePProf {
+		for path, handler := range debugutil.PProfHandlers() {
+			handlers[path] = handler
+		}
+	}
+	if s.opts.EnableDebugHandlers {
+		for path, handler := range debug.Handlers(debug.Options{
+			PolicyManagers: s.policyManagers,
+			DynamicLister:  s.drLister,
+			Resources:      s.preCacheTracker.Resources,
+		}) {
+			handlers[path] = handler
+		}
+	}
+	if len(handlers) == 0 {
+		return nil
 	}
+
+	filter, err := s.debugFilter()
+	if err != nil {
+		return err
+	}
+	if filter != nil {
+		for path, handler := range handlers {
+			handlers[path] = filter(handler)
+		}
+	}
+
+	if s.opts.DebugBindAddress != "" {
+		return s.hookManager.Add(&debug.Server{BindAddress: s.opts.DebugBindAddress, Handlers: handlers})
+	}
+	for path, handler := range handlers {
+		if err := s.addMetricsExtraHandler(path, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupSecureMetrics serves metrics over HTTPS with delegated authentication and authorization.
+func (s *setupManager) setupSecureMetrics() error {
+	certDir := s.opts.MetricsCertDir
+	if certDir == "" {
+		certDir = s.opts.CertDir
+	}
+	tlsConfig, err := s.opts.TLSConfig()
+	if err != nil {
+		return err
+	}
+	filter, err := s.delegatingFilter()
+	if err != nil {
+		return err
+	}
+
+	s.metricsServer = metricsserver.New(metricsserver.Options{
+		BindAddress: s.opts.MetricsBindAddress,
+		CertDir:     certDir,
+		TLS:         tlsConfig,
+		Filter:      filter,
+	})
+	return s.hookManager.Add(s.metricsServer)
+}
+
+// addMetricsExtraHandler adds the handler to the secure metrics server if it is enabled,
+// otherwise to the metrics server of the manager.
+func (s *setupManager) addMetricsExtraHandler(path string, handler http.Handler) error {
+	if s.metricsServer != nil {
+		return s.metricsServer.AddExtraHandler(path, handler)
+	}
+	return s.hookManager.AddMetricsExtraHandler(path, handler)
+}
+
+// delegatingFilter returns a filter authenticating requests by TokenReview and authorizing them by SubjectAccessReview.
+func (s *setupManager) delegatingFilter() (auth.Filter, error) {
+	client, err := kubernetes.NewForConfig(s.hookManager.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+	return auth.Delegating(client.AuthenticationV1().TokenReviews(), client.AuthorizationV1().SubjectAccessReviews(), 0), nil
+}
+
+// debugFilter returns the filter protecting debug handlers, nil if they are not protected.
+func (s *setupManager) debugFilter() (auth.Filter, error) {
+	switch {
+	case s.opts.DebugDelegatedAuth:
+		return s.delegatingFilter()
+	case s.opts.DebugTokenFile != "":
+		data, err := os.ReadFile(s.opts.DebugTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		return auth.BearerToken(strings.TrimSpace(string(data))), nil
+	default:
+		klog.InfoS("debug handlers are not protected, set --debug-token-file or --debug-delegated-auth to protect them.")
+		return nil, nil
+	}
+}
+
+func (s *setupManager) setupDeleteHookController() error {
+	controller := deletehook.NewController(dynamic.NewForConfigOrDie(s.hookManager.GetConfig()), s.policyManagers.CheckDeleteHooks, deletehook.Options{
+		Resources:  s.policyManagers.DeleteHookResources(s.restMapper),
+		RESTMapper: s.restMapper,
+	})
+	s.deleteHook = controller.Enqueue
+
+	return s.hookManager.Add(controller)
+}
+
+func (s *setupManager) setupNamespaceInformer() error {
+	s.informerManager.Informer(nsGVR)
+	s.informerManager.Start()
+	if result := s.informerManager.WaitForCacheSync(); !result[nsGVR] {
+		return errors.New("failed to sync namespace")
+	}
+
+	return nil
+}
+
+// namespaceLabels returns labels of the namespace from informer cache.
+func (s *setupManager) namespaceLabels(name string) (map[string]string, bool) {
+	obj, exists, err := s.informerManager.Informer(nsGVR).GetStore().GetByKey(name)
+	if err != nil || !exists {
+		return nil, false
+	}
+
+	ns, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, false
+	}
+
+	return ns.GetLabels(), true
+}
+
+func (s *setupManager) setupOverridePolicyManager() (err error) {
 	opInformer := s.informerManager.Informer(opGVR)
 	copInformer := s.informerManager.Informer(copGVR)
 
@@ -318,6 +621,11 @@ func (s *setupManager) setupOverridePolicyManager() (err error) {
 		},
 	})
 
+	if s.opts.AutoPreCacheResources {
+		opInformer.AddEventHandler(s.preCacheTracker.EventHandler())
+		copInformer.AddEventHandler(s.preCacheTracker.EventHandler())
+	}
+
 	s.informerManager.Start()
 	if result := s.informerManager.WaitForCacheSync(); !result[opGVR] || !result[copGVR] {
 		return errors.New("failed to sync override policy")
@@ -325,16 +633,12 @@ func (s *setupManager) setupOverridePolicyManager() (err error) {
 
 	s.opLister = lister.NewUnstructuredOverridePolicyLister(opInformer.GetIndexer())
 	s.copLister = lister.NewUnstructuredClusterOverridePolicyLister(copInformer.GetIndexer())
-	s.overrideManager = overridemanager.NewOverrideManager(s.drLister, s.copLister, s.opLister)
+	s.policyManagers.OverridePolicyLister = s.opLister
+	s.policyManagers.ClusterOverridePolicyLister = s.copLister
 	return nil
 }
 
 func (s *setupManager) setupValidatePolicyManager() (err error) {
-	cvpGVR := schema.GroupVersionResource{
-		Group:    policyv1alpha1.SchemeGroupVersion.Group,
-		Version:  policyv1alpha1.SchemeGroupVersion.Version,
-		Resource: "clustervalidatepolicies",
-	}
 	cvpInformer := s.informerManager.Informer(cvpGVR)
 
 	cvpInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -346,12 +650,16 @@ func (s *setupManager) setupValidatePolicyManager() (err error) {
 		},
 	})
 
+	if s.opts.AutoPreCacheResources {
+		cvpInformer.AddEventHandler(s.preCacheTracker.EventHandler())
+	}
+
 	s.informerManager.Start()
 	if result := s.informerManager.WaitForCacheSync(); !result[cvpGVR] {
 		return errors.New("failed to sync validate policy")
 	}
 
 	s.cvpLister = lister.NewUnstructuredClusterValidatePolicyLister(cvpInformer.GetIndexer())
-	s.validateManager = validatemanager.NewValidateManager(s.drLister, s.cvpLister)
+	s.policyManagers.ClusterValidatePolicyLister = s.cvpLister
 	return nil
 }
@@ -0,0 +1,129 @@
+package allowlist
+
+import (
+	"strings"
+	"sync/atomic"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	configv1alpha1 "github.com/k-cloud-labs/kinitiras/pkg/apis/config/v1alpha1"
+	"github.com/k-cloud-labs/kinitiras/pkg/policyfilter"
+)
+
+const serviceAccountPrefix = "system:serviceaccount:"
+
+// policyGroup is the group of kinitiras policies, they are never allowlisted so policies created in allowlisted
+// namespaces or by allowlisted users are still rendered and validated.
+const policyGroup = "policy.kcloudlabs.io"
+
+// Allowlist decides whether an admission request bypasses all policies.
+// It is safe for concurrent use and can be updated at runtime.
+type Allowlist struct {
+	rules           atomic.Value // *rules
+	namespaceLabels policyfilter.NamespaceLabelsFunc
+}
+
+type rules struct {
+	namespaces        sets.String
+	namespaceSelector labels.Selector
+	users             sets.String
+	groups            sets.String
+	serviceAccounts   sets.String
+	resources         sets.String
+}
+
+// New builds an Allowlist from configuration. namespaceLabels is used to evaluate the
+// namespace selector, it can be nil if no namespace selector is configured.
+func New(cfg configv1alpha1.AllowlistConfiguration, namespaceLabels policyfilter.NamespaceLabelsFunc) *Allowlist {
+	a := &Allowlist{namespaceLabels: namespaceLabels}
+	a.Update(cfg)
+	return a
+}
+
+// Update replaces the rules of the allowlist. The configuration is expected to be validated.
+func (a *Allowlist) Update(cfg configv1alpha1.AllowlistConfiguration) {
+	r := &rules{
+		namespaces:      sets.NewString(cfg.Namespaces...),
+		users:           sets.NewString(cfg.Users...),
+		groups:          sets.NewString(cfg.Groups...),
+		serviceAccounts: sets.NewString(),
+		resources:       sets.NewString(),
+	}
+
+	for _, sa := range cfg.ServiceAccounts {
+		r.serviceAccounts.Insert(serviceAccountPrefix + strings.Replace(sa, "/", ":", 1))
+	}
+
+	for _, res := range cfg.Resources {
+		gvk, err := ParseResource(res)
+		if err != nil {
+			klog.ErrorS(err, "skip invalid allowlist resource.")
+			continue
+		}
+		r.resources.Insert(gvk.String())
+	}
+
+	if cfg.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(cfg.NamespaceSelector)
+		if err != nil {
+			klog.ErrorS(err, "skip invalid allowlist namespace selector.")
+		} else {
+			r.namespaceSelector = selector
+		}
+	}
+
+	a.rules.Store(r)
+}
+
+// Match returns true if the request should bypass all policies.
+// A nil Allowlist matches nothing, and requests of policies are never matched.
+func (a *Allowlist) Match(req admission.Request) bool {
+	if a == nil || req.Kind.Group == policyGroup {
+		return false
+	}
+
+	r, ok := a.rules.Load().(*rules)
+	if !ok {
+		return false
+	}
+
+	if req.Namespace != "" && r.namespaces.Has(req.Namespace) {
+		return true
+	}
+
+	if r.users.Has(req.UserInfo.Username) || r.groups.HasAny(req.UserInfo.Groups...) {
+		return true
+	}
+
+	username := req.UserInfo.Username
+	if strings.HasPrefix(username, serviceAccountPrefix) {
+		if r.serviceAccounts.Has(username) || r.serviceAccounts.Has(username[:strings.LastIndex(username, ":")+1]+"*") {
+			return true
+		}
+	}
+
+	kind := schema.GroupVersionKind{Group: req.Kind.Group, Version: req.Kind.Version, Kind: req.Kind.Kind}
+	if r.resources.Has(kind.String()) {
+		return true
+	}
+
+	return r.namespaceSelector != nil && req.Namespace != "" && a.matchNamespaceSelector(r.namespaceSelector, req.Namespace)
+}
+
+func (a *Allowlist) matchNamespaceSelector(selector labels.Selector, namespace string) bool {
+	if a.namespaceLabels == nil {
+		return false
+	}
+
+	nsLabels, ok := a.namespaceLabels(namespace)
+	if !ok {
+		return false
+	}
+
+	return selector.Matches(labels.Set(nsLabels))
+}
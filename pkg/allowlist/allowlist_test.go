@@ -0,0 +1,130 @@
+package allowlist
+
+import (
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	configv1alpha1 "github.com/k-cloud-labs/kinitiras/pkg/apis/config/v1alpha1"
+)
+
+func newRequest(namespace, username string) admission.Request {
+	return admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Namespace: namespace,
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+		},
+	}
+}
+
+func TestAllowlist_Match(t *testing.T) {
+	cfg := configv1alpha1.AllowlistConfiguration{
+		Namespaces: []string{"kube-system"},
+		Users:      []string{"system:serviceaccount:argocd:argocd-application-controller"},
+	}
+	tests := []struct {
+		name string
+		list *Allowlist
+		req  admission.Request
+		want bool
+	}{
+		{
+			name: "nil",
+			list: nil,
+			req:  newRequest("kube-system", ""),
+			want: false,
+		},
+		{
+			name: "namespace",
+			list: New(cfg, nil),
+			req:  newRequest("kube-system", "admin"),
+			want: true,
+		},
+		{
+			name: "user",
+			list: New(cfg, nil),
+			req:  newRequest("default", "system:serviceaccount:argocd:argocd-application-controller"),
+			want: true,
+		},
+		{
+			name: "not matched",
+			list: New(cfg, nil),
+			req:  newRequest("default", "admin"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.list.Match(tt.req); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowlist_Update(t *testing.T) {
+	a := New(configv1alpha1.AllowlistConfiguration{}, nil)
+	req := newRequest("kube-system", "")
+	if a.Match(req) {
+		t.Fatalf("Match() = true before update")
+	}
+
+	a.Update(configv1alpha1.AllowlistConfiguration{Namespaces: []string{"kube-system"}})
+	if !a.Match(req) {
+		t.Fatalf("Match() = false after update")
+	}
+}
+
+func TestAllowlist_MatchRules(t *testing.T) {
+	cfg := configv1alpha1.AllowlistConfiguration{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"kinitiras.kcloudlabs.io/ignore": "true"}},
+		Groups:            []string{"system:masters"},
+		ServiceAccounts:   []string{"argocd/argocd-server", "flux-system/*"},
+		Resources:         []string{"Lease/coordination.k8s.io/v1", "Event/v1"},
+	}
+	nsLabels := func(namespace string) (map[string]string, bool) {
+		if namespace == "ignored" {
+			return map[string]string{"kinitiras.kcloudlabs.io/ignore": "true"}, true
+		}
+		return nil, false
+	}
+	list := New(cfg, nsLabels)
+
+	withGroups := newRequest("default", "admin")
+	withGroups.UserInfo.Groups = []string{"system:authenticated", "system:masters"}
+	lease := newRequest("default", "admin")
+	lease.Kind = metav1.GroupVersionKind{Group: "coordination.k8s.io", Version: "v1", Kind: "Lease"}
+	event := newRequest("default", "admin")
+	event.Kind = metav1.GroupVersionKind{Version: "v1", Kind: "Event"}
+	pod := newRequest("default", "admin")
+	pod.Kind = metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	policy := newRequest("ignored", "system:serviceaccount:argocd:argocd-server")
+	policy.Kind = metav1.GroupVersionKind{Group: "policy.kcloudlabs.io", Version: "v1alpha1", Kind: "OverridePolicy"}
+
+	tests := []struct {
+		name string
+		req  admission.Request
+		want bool
+	}{
+		{name: "group", req: withGroups, want: true},
+		{name: "service account", req: newRequest("default", "system:serviceaccount:argocd:argocd-server"), want: true},
+		{name: "service account other name", req: newRequest("default", "system:serviceaccount:argocd:argocd-repo-server"), want: false},
+		{name: "service account wildcard", req: newRequest("default", "system:serviceaccount:flux-system:kustomize-controller"), want: true},
+		{name: "resource", req: lease, want: true},
+		{name: "core resource", req: event, want: true},
+		{name: "resource not matched", req: pod, want: false},
+		{name: "namespace selector", req: newRequest("ignored", "admin"), want: true},
+		{name: "namespace selector not matched", req: newRequest("default", "admin"), want: false},
+		{name: "policy", req: policy, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := list.Match(tt.req); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
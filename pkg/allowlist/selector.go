@@ -0,0 +1,86 @@
+package allowlist
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	configv1alpha1 "github.com/k-cloud-labs/kinitiras/pkg/apis/config/v1alpha1"
+)
+
+// namespaceNameLabel is set on every namespace by kube-apiserver since v1.21.
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// ParseResource parses a kind in Kind/version or Kind/group/version format.
+func ParseResource(val string) (schema.GroupVersionKind, error) {
+	var gvk schema.GroupVersionKind
+	items := strings.Split(val, "/")
+	for _, item := range items {
+		if item == "" {
+			return gvk, fmt.Errorf("invalid gvk(%v)", val)
+		}
+	}
+
+	switch len(items) {
+	case 2:
+		gvk.Kind, gvk.Version = items[0], items[1]
+	case 3:
+		gvk.Kind, gvk.Group, gvk.Version = items[0], items[1], items[2]
+	default:
+		return gvk, fmt.Errorf("invalid gvk(%v)", val)
+	}
+
+	return gvk, nil
+}
+
+// WebhookNamespaceSelector returns the namespaceSelector of webhook configurations which excludes
+// the namespaces on the allowlist, so apiserver does not call the webhook for them at all.
+//
+// Namespaces are excluded by the kubernetes.io/metadata.name label. The namespace selector of the
+// allowlist can only be negated when it has exactly one requirement, otherwise it is enforced
+// by the webhook only. The selector must not be set to webhooks of policies, which are never allowlisted.
+func WebhookNamespaceSelector(cfg configv1alpha1.AllowlistConfiguration) *metav1.LabelSelector {
+	selector := &metav1.LabelSelector{}
+	if len(cfg.Namespaces) != 0 {
+		selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      namespaceNameLabel,
+			Operator: metav1.LabelSelectorOpNotIn,
+			Values:   cfg.Namespaces,
+		})
+	}
+
+	if requirement, ok := negate(cfg.NamespaceSelector); ok {
+		selector.MatchExpressions = append(selector.MatchExpressions, requirement)
+	}
+
+	return selector
+}
+
+// negate returns the requirement matching namespaces not selected by selector.
+func negate(selector *metav1.LabelSelector) (metav1.LabelSelectorRequirement, bool) {
+	if selector == nil || len(selector.MatchLabels)+len(selector.MatchExpressions) != 1 {
+		return metav1.LabelSelectorRequirement{}, false
+	}
+
+	for key, value := range selector.MatchLabels {
+		return metav1.LabelSelectorRequirement{Key: key, Operator: metav1.LabelSelectorOpNotIn, Values: []string{value}}, true
+	}
+
+	requirement := *selector.MatchExpressions[0].DeepCopy()
+	switch requirement.Operator {
+	case metav1.LabelSelectorOpIn:
+		requirement.Operator = metav1.LabelSelectorOpNotIn
+	case metav1.LabelSelectorOpNotIn:
+		requirement.Operator = metav1.LabelSelectorOpIn
+	case metav1.LabelSelectorOpExists:
+		requirement.Operator = metav1.LabelSelectorOpDoesNotExist
+	case metav1.LabelSelectorOpDoesNotExist:
+		requirement.Operator = metav1.LabelSelectorOpExists
+	default:
+		return metav1.LabelSelectorRequirement{}, false
+	}
+
+	return requirement, true
+}
@@ -0,0 +1,60 @@
+package allowlist
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv1alpha1 "github.com/k-cloud-labs/kinitiras/pkg/apis/config/v1alpha1"
+)
+
+func TestWebhookNamespaceSelector(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  configv1alpha1.AllowlistConfiguration
+		want *metav1.LabelSelector
+	}{
+		{
+			name: "empty",
+			cfg:  configv1alpha1.AllowlistConfiguration{},
+			want: &metav1.LabelSelector{},
+		},
+		{
+			name: "namespaces and labels",
+			cfg: configv1alpha1.AllowlistConfiguration{
+				Namespaces:        []string{"kube-system"},
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"ignore": "true"}},
+			},
+			want: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: namespaceNameLabel, Operator: metav1.LabelSelectorOpNotIn, Values: []string{"kube-system"}},
+				{Key: "ignore", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"true"}},
+			}},
+		},
+		{
+			name: "expression",
+			cfg: configv1alpha1.AllowlistConfiguration{
+				NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "ignore", Operator: metav1.LabelSelectorOpExists},
+				}},
+			},
+			want: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "ignore", Operator: metav1.LabelSelectorOpDoesNotExist},
+			}},
+		},
+		{
+			name: "multiple requirements",
+			cfg: configv1alpha1.AllowlistConfiguration{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"a": "1", "b": "2"}},
+			},
+			want: &metav1.LabelSelector{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WebhookNamespaceSelector(tt.cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WebhookNamespaceSelector() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
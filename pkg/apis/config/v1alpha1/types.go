@@ -0,0 +1,164 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// GroupName is the group name of the kinitiras configuration API.
+	GroupName = "config.kcloudlabs.io"
+	// Kind is the kind of the kinitiras configuration file.
+	Kind = "WebhookConfiguration"
+)
+
+// SchemeGroupVersion is group version used to identify the configuration file.
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha1"}
+
+// WebhookConfiguration is the configuration file of kinitiras webhook server.
+// Fields left empty fall back to the value of the corresponding command line flag.
+type WebhookConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Server contains settings of the webhook and metrics servers.
+	// Changes require a restart.
+	// +optional
+	Server ServerConfiguration `json:"server,omitempty"`
+	// Cert contains settings of the self-signed certificate rotator.
+	// Changes require a restart.
+	// +optional
+	Cert CertConfiguration `json:"cert,omitempty"`
+	// Cache contains settings of the resources cached by the dynamic lister.
+	// PreCacheResources is reloaded without restart.
+	// +optional
+	Cache CacheConfiguration `json:"cache,omitempty"`
+	// DataSource contains settings of the client used to fetch data referred by policies.
+	// Changes require a restart.
+	// +optional
+	DataSource DataSourceConfiguration `json:"dataSource,omitempty"`
+	// Logging contains logging settings. Reloaded without restart.
+	// +optional
+	Logging LoggingConfiguration `json:"logging,omitempty"`
+	// Allowlist contains requests which always bypass policies. Reloaded without restart.
+	// +optional
+	Allowlist AllowlistConfiguration `json:"allowlist,omitempty"`
+}
+
+// ServerConfiguration contains settings of the webhook and metrics servers.
+type ServerConfiguration struct {
+	// BindAddress is the IP address on which to listen for the secure port.
+	// +optional
+	BindAddress string `json:"bindAddress,omitempty"`
+	// SecurePort is the port that the webhook server serves at.
+	// +optional
+	SecurePort int `json:"securePort,omitempty"`
+	// MetricsBindAddress is the IP:Port address on which to listen for the webhook metrics.
+	// +optional
+	MetricsBindAddress string `json:"metricsBindAddress,omitempty"`
+	// CertDir is the directory that contains the server key and certificate.
+	// +optional
+	CertDir string `json:"certDir,omitempty"`
+	// TLSMinVersion is the minimum version of TLS supported. Possible values: 1.0, 1.1, 1.2, 1.3.
+	// +optional
+	TLSMinVersion string `json:"tlsMinVersion,omitempty"`
+	// EnablePProf is switch to enable/disable net/http/pprof.
+	// +optional
+	EnablePProf *bool `json:"enablePProf,omitempty"`
+	// ShutdownDrainPeriod is the period to keep serving with failing readiness after a termination signal.
+	// +optional
+	ShutdownDrainPeriod *metav1.Duration `json:"shutdownDrainPeriod,omitempty"`
+}
+
+// CertConfiguration contains settings of the self-signed certificate rotator.
+type CertConfiguration struct {
+	// Namespace is the namespace of the secret which stores certificates.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// SecretName is the name of the secret which stores certificates.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// CAName is the common name of the generated CA.
+	// +optional
+	CAName string `json:"caName,omitempty"`
+	// CAOrganization is the organization of the generated CA.
+	// +optional
+	CAOrganization string `json:"caOrganization,omitempty"`
+	// ServiceName is the name of the service in front of the webhook server.
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+	// MutatingConfig is the name of the MutatingWebhookConfiguration to inject caBundle into.
+	// +optional
+	MutatingConfig string `json:"mutatingConfig,omitempty"`
+	// ValidatingConfig is the name of the ValidatingWebhookConfiguration to inject caBundle into.
+	// +optional
+	ValidatingConfig string `json:"validatingConfig,omitempty"`
+	// ExtraDNSNames are the DNS names of the serving certificate besides <serviceName>.<namespace>.svc.
+	// +optional
+	ExtraDNSNames []string `json:"extraDNSNames,omitempty"`
+	// Validity is the validity of the generated CA and serving certificate, 0 uses the default.
+	// +optional
+	Validity *metav1.Duration `json:"validity,omitempty"`
+	// Lookahead is how long before expiration certificates are rotated, 0 uses the default.
+	// +optional
+	Lookahead *metav1.Duration `json:"lookahead,omitempty"`
+	// CABundleTargets are additional webhook configurations, CRDs and APIServices to inject caBundle into,
+	// in Kind/name format, e.g. CustomResourceDefinition/foos.example.com.
+	// +optional
+	CABundleTargets []string `json:"caBundleTargets,omitempty"`
+	// CABundleSelector is a label selector of more webhook configurations, CRDs and APIServices to inject caBundle into,
+	// e.g. app=kinitiras.
+	// +optional
+	CABundleSelector string `json:"caBundleSelector,omitempty"`
+}
+
+// CacheConfiguration contains settings of the resources cached by the dynamic lister.
+type CacheConfiguration struct {
+	// PreCacheResources is a list of resources to pre-cache, for example: Pod/v1, Deployment/apps/v1.
+	// +optional
+	PreCacheResources []string `json:"preCacheResources,omitempty"`
+	// AutoPreCacheResources is switch to cache the resources referred by policies automatically.
+	// Changes require a restart.
+	// +optional
+	AutoPreCacheResources *bool `json:"autoPreCacheResources,omitempty"`
+}
+
+// DataSourceConfiguration contains settings of the client used to talk with kube-apiserver.
+type DataSourceConfiguration struct {
+	// KubeAPIQPS is the QPS to use while talking with kube-apiserver.
+	// +optional
+	KubeAPIQPS float32 `json:"kubeAPIQPS,omitempty"`
+	// KubeAPIBurst is the burst to allow while talking with kube-apiserver.
+	// +optional
+	KubeAPIBurst int `json:"kubeAPIBurst,omitempty"`
+}
+
+// LoggingConfiguration contains logging settings.
+type LoggingConfiguration struct {
+	// Verbosity is the klog verbosity level.
+	// +optional
+	Verbosity *int32 `json:"verbosity,omitempty"`
+}
+
+// AllowlistConfiguration contains requests which always bypass policies.
+// A request matching any of the rules below is allowed without applying policies.
+type AllowlistConfiguration struct {
+	// Namespaces is a list of namespaces whose requests bypass policies.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector selects namespaces by labels whose requests bypass policies.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// Users is a list of usernames whose requests bypass policies.
+	// +optional
+	Users []string `json:"users,omitempty"`
+	// Groups is a list of user groups whose requests bypass policies.
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+	// ServiceAccounts is a list of service accounts in namespace/name format whose requests bypass policies.
+	// Name can be `*` to match all service accounts of the namespace.
+	// +optional
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+	// Resources is a list of kinds in Kind/version or Kind/group/version format which bypass policies.
+	// +optional
+	Resources []string `json:"resources,omitempty"`
+}
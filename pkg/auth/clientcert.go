@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/x509"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+// ClientCertificate returns a Filter which only passes requests with a client certificate verified by the TLS
+// server, e.g. by the ClientCAs of a tls.Config with tls.VerifyClientCertIfGiven. If allowedNames is not empty,
+// the common name or one of the DNS names of the certificate must be in it as well.
+func ClientCertificate(allowedNames []string) Filter {
+	allowed := sets.NewString(allowedNames...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+				klog.V(2).InfoS("request without verified client certificate.", "path", r.URL.Path, "remote", r.RemoteAddr)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			cert := r.TLS.VerifiedChains[0][0]
+			if allowed.Len() != 0 && !allowed.HasAny(certificateNames(cert)...) {
+				klog.V(2).InfoS("forbidden client certificate.", "path", r.URL.Path, "commonName", cert.Subject.CommonName, "dnsNames", cert.DNSNames)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func certificateNames(cert *x509.Certificate) []string {
+	names := make([]string, 0, len(cert.DNSNames)+1)
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	return append(names, cert.DNSNames...)
+}
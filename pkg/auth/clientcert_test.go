@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientCertificate(t *testing.T) {
+	apiserver := &x509.Certificate{Subject: pkix.Name{CommonName: "kube-apiserver-webhook-client"}}
+	proxy := &x509.Certificate{Subject: pkix.Name{CommonName: "proxy"}, DNSNames: []string{"apiserver.example.com"}}
+
+	tests := []struct {
+		name         string
+		allowedNames []string
+		state        *tls.ConnectionState
+		want         int
+	}{
+		{
+			name:  "1",
+			state: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{apiserver}}},
+			want:  http.StatusOK,
+		},
+		{
+			name:         "common name",
+			allowedNames: []string{"kube-apiserver-webhook-client"},
+			state:        &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{apiserver}}},
+			want:         http.StatusOK,
+		},
+		{
+			name:         "dns name",
+			allowedNames: []string{"apiserver.example.com"},
+			state:        &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{proxy}}},
+			want:         http.StatusOK,
+		},
+		{
+			name:         "not allowed",
+			allowedNames: []string{"kube-apiserver-webhook-client"},
+			state:        &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{proxy}}},
+			want:         http.StatusForbidden,
+		},
+		{
+			name:  "not verified",
+			state: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{apiserver}},
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name: "no tls",
+			want: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ClientCertificate(tt.allowedNames)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodPost, "/validate", nil)
+			req.TLS = tt.state
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %v, want %v", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	authenticationclient "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// defaultCacheTTL is how long results of TokenReviews and SubjectAccessReviews are cached.
+	defaultCacheTTL = 10 * time.Second
+	cacheSize       = 1024
+)
+
+// Filter wraps a handler to authenticate and authorize requests.
+type Filter func(http.Handler) http.Handler
+
+// BearerToken returns a Filter which only passes requests with the bearer token.
+func BearerToken(token string) Filter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(token)) != 1 {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Delegating returns a Filter which authenticates the bearer token of requests by TokenReview, and authorizes
+// the user by SubjectAccessReview of the non-resource url, e.g. verb get on /debug/pprof/. Results are cached
+// for ttl, 10s if it is not positive.
+func Delegating(tokenReviews authenticationclient.TokenReviewInterface, accessReviews authorizationclient.SubjectAccessReviewInterface,
+	ttl time.Duration) Filter {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	d := &delegating{
+		tokenReviews:  tokenReviews,
+		accessReviews: accessReviews,
+		ttl:           ttl,
+		users:         cache.NewLRUExpireCache(cacheSize),
+		decisions:     cache.NewLRUExpireCache(cacheSize),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			user, err := d.authenticate(r.Context(), token)
+			if err != nil {
+				klog.V(2).InfoS("failed to authenticate request.", "path", r.URL.Path, "err", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			allowed, err := d.authorize(r.Context(), user, strings.ToLower(r.Method), r.URL.Path)
+			if err != nil {
+				klog.ErrorS(err, "failed to authorize request.", "path", r.URL.Path, "user", user.Username)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if !allowed {
+				klog.V(2).InfoS("forbidden request.", "path", r.URL.Path, "user", user.Username)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type delegating struct {
+	tokenReviews  authenticationclient.TokenReviewInterface
+	accessReviews authorizationclient.SubjectAccessReviewInterface
+	ttl           time.Duration
+	// users caches users by token
+	users *cache.LRUExpireCache
+	// decisions caches decisions by user, verb and path
+	decisions *cache.LRUExpireCache
+}
+
+func (d *delegating) authenticate(ctx context.Context, token string) (authenticationv1.UserInfo, error) {
+	if user, ok := d.users.Get(token); ok {
+		return user.(authenticationv1.UserInfo), nil
+	}
+
+	review, err := d.tokenReviews.Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return authenticationv1.UserInfo{}, err
+	}
+	if !review.Status.Authenticated {
+		return authenticationv1.UserInfo{}, fmt.Errorf("token is not authenticated: %s", review.Status.Error)
+	}
+
+	d.users.Add(token, review.Status.User, d.ttl)
+	return review.Status.User, nil
+}
+
+func (d *delegating) authorize(ctx context.Context, user authenticationv1.UserInfo, verb, path string) (bool, error) {
+	key := strings.Join([]string{user.Username, user.UID, strings.Join(user.Groups, ","), verb, path}, "\x00")
+	if allowed, ok := d.decisions.Get(key); ok {
+		return allowed.(bool), nil
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review, err := d.accessReviews.Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:                  user.Username,
+			UID:                   user.UID,
+			Groups:                user.Groups,
+			Extra:                 extra,
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{Path: path, Verb: verb},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	d.decisions.Add(key, review.Status.Allowed, d.ttl)
+	return review.Status.Allowed, nil
+}
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestBearerToken(t *testing.T) {
+	handler := BearerToken("secret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{name: "1", authorization: "Bearer secret", want: http.StatusOK},
+		{name: "wrong token", authorization: "Bearer other", want: http.StatusUnauthorized},
+		{name: "no token", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %v, want %v", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelegating(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		review.Status.Authenticated = review.Spec.Token == "admin" || review.Spec.Token == "viewer"
+		review.Status.User = authenticationv1.UserInfo{Username: review.Spec.Token}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		review.Status.Allowed = review.Spec.User == "admin" && review.Spec.NonResourceAttributes.Verb == "get"
+		return true, review, nil
+	})
+
+	filter := Delegating(client.AuthenticationV1().TokenReviews(), client.AuthorizationV1().SubjectAccessReviews(), 0)
+	handler := filter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		want   int
+	}{
+		{name: "1", token: "admin", method: http.MethodGet, want: http.StatusOK},
+		{name: "cached", token: "admin", method: http.MethodGet, want: http.StatusOK},
+		{name: "forbidden verb", token: "admin", method: http.MethodPost, want: http.StatusForbidden},
+		{name: "forbidden user", token: "viewer", method: http.MethodGet, want: http.StatusForbidden},
+		{name: "unauthenticated", token: "unknown", method: http.MethodGet, want: http.StatusUnauthorized},
+		{name: "no token", method: http.MethodGet, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/debug/pprof/", nil).WithContext(context.Background())
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %v, want %v", w.Code, tt.want)
+			}
+		})
+	}
+
+	reviews := 0
+	for _, action := range client.Actions() {
+		if action.GetResource().Resource == "tokenreviews" {
+			reviews++
+		}
+	}
+	if reviews != 3 {
+		t.Errorf("TokenReviews = %d, want 3 since results are cached", reviews)
+	}
+}
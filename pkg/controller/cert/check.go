@@ -0,0 +1,51 @@
+package cert
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// CertFile is the name of the serving certificate in the cert dir.
+const CertFile = "tls.crt"
+
+// Checker returns a healthz.Checker which fails if the serving certificate in certDir is missing,
+// invalid, not valid yet or expired.
+func Checker(certDir string) healthz.Checker {
+	return func(_ *http.Request) error {
+		cert, err := ReadCertificate(filepath.Join(certDir, CertFile))
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if now.Before(cert.NotBefore) {
+			return fmt.Errorf("certificate is not valid before %s", cert.NotBefore.Format(time.RFC3339))
+		}
+		if now.After(cert.NotAfter) {
+			return fmt.Errorf("certificate expired at %s", cert.NotAfter.Format(time.RFC3339))
+		}
+		return nil
+	}
+}
+
+// ReadCertificate reads the first certificate of a PEM file.
+func ReadCertificate(file string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no certificate found in " + file)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
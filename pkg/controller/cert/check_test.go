@@ -0,0 +1,64 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCertificate(t *testing.T, dir string, notBefore, notAfter time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: DefaultServiceName},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, CertFile), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChecker(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		notBefore time.Time
+		notAfter  time.Time
+		missing   bool
+		wantErr   bool
+	}{
+		{name: "1", notBefore: now.Add(-time.Hour), notAfter: now.Add(time.Hour)},
+		{name: "expired", notBefore: now.Add(-2 * time.Hour), notAfter: now.Add(-time.Hour), wantErr: true},
+		{name: "not valid yet", notBefore: now.Add(time.Hour), notAfter: now.Add(2 * time.Hour), wantErr: true},
+		{name: "missing", missing: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if !tt.missing {
+				writeCertificate(t, dir, tt.notBefore, tt.notAfter)
+			}
+
+			if err := Checker(dir)(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Checker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package cert
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// injector injects the CA of the secret into the caBundle of webhooks, whenever the secret or a webhook changes.
+type injector struct {
+	cache     cache.Cache
+	writer    client.Writer
+	secretKey types.NamespacedName
+	webhooks  []WebhookInfo
+	// selector selects webhook configurations, CRDs and APIServices to inject the CA into besides webhooks,
+	// they are cached by selectorCache only. Nil means none.
+	selector      labels.Selector
+	selectorCache cache.Cache
+	// injected is set to 1 once the CA is injected into all webhooks found
+	injected *int32
+}
+
+var _ reconcile.Reconciler = &injector{}
+
+func addInjector(mgr manager.Manager, r *injector) error {
+	c, err := controller.New("cert-rotator", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	enqueueSecret := func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: r.secretKey}}
+	}
+	if err := c.Watch(source.NewKindWithCache(&corev1.Secret{}, r.cache), &handler.EnqueueRequestForObject{}); err != nil {
+		return fmt.Errorf("watching secrets: %w", err)
+	}
+
+	names := make(map[WebhookType]sets.String)
+	for _, webhook := range r.webhooks {
+		if names[webhook.Type] == nil {
+			names[webhook.Type] = sets.NewString()
+		}
+		names[webhook.Type].Insert(webhook.Name)
+	}
+	for _, typ := range webhookTypes {
+		typeNames, ok := names[typ]
+		if !ok {
+			continue
+		}
+		if err := c.Watch(source.NewKindWithCache(newWebhookObject(typ), r.cache), handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+			if !typeNames.Has(o.GetName()) {
+				return nil
+			}
+			return enqueueSecret(o)
+		})); err != nil {
+			return fmt.Errorf("watching %s: %w", webhookKinds[typ].Kind, err)
+		}
+	}
+
+	if r.selector == nil {
+		return nil
+	}
+	for _, typ := range webhookTypes {
+		// the selector cache only holds selected objects
+		if err := c.Watch(source.NewKindWithCache(newWebhookObject(typ), r.selectorCache), handler.EnqueueRequestsFromMapFunc(enqueueSecret)); err != nil {
+			return fmt.Errorf("watching %s selected by %s: %w", webhookKinds[typ].Kind, r.selector, err)
+		}
+	}
+
+	return nil
+}
+
+// newWebhookObject returns an empty object of the kind of typ.
+func newWebhookObject(typ WebhookType) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(webhookKinds[typ])
+	return obj
+}
+
+// Reconcile implements reconcile.Reconciler, it only handles the secret of certificates.
+func (r *injector) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	if request.NamespacedName != r.secretKey {
+		return reconcile.Result{}, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.cache.Get(ctx, request.NamespacedName, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if !secret.GetDeletionTimestamp().IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	ca, err := caFromSecret(secret)
+	if err != nil {
+		klog.ErrorS(err, "secret is not well-formed, cannot update webhooks.", "secret", r.secretKey)
+		return reconcile.Result{}, nil
+	}
+	if err := r.injectAll(ctx, ca.certPEM); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	atomic.StoreInt32(r.injected, 1)
+	return reconcile.Result{}, nil
+}
+
+// injectAll injects the CA into all webhooks and selected objects. Errors are logged and the last one is returned,
+// so the secret is reconciled again while other webhooks are still updated.
+func (r *injector) injectAll(ctx context.Context, caPEM []byte) error {
+	var lastErr error
+	injected := make(map[WebhookType]sets.String)
+	for _, typ := range webhookTypes {
+		injected[typ] = sets.NewString()
+	}
+
+	for _, webhook := range r.webhooks {
+		kind := webhookKinds[webhook.Type].Kind
+		obj := newWebhookObject(webhook.Type)
+		if err := r.cache.Get(ctx, types.NamespacedName{Name: webhook.Name}, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				klog.ErrorS(err, "webhook not found, unable to inject CA.", "kind", kind, "name", webhook.Name)
+				recordInjectionFailure(kind, webhook.Name)
+				continue
+			}
+			klog.ErrorS(err, "failed to get webhook to inject CA.", "kind", kind, "name", webhook.Name)
+			recordInjectionFailure(kind, webhook.Name)
+			lastErr = err
+			continue
+		}
+		injected[webhook.Type].Insert(webhook.Name)
+		if err := r.inject(ctx, obj, caPEM, webhook.Type); err != nil {
+			lastErr = err
+		}
+	}
+
+	if r.selector == nil {
+		return lastErr
+	}
+	for _, typ := range webhookTypes {
+		gvk := webhookKinds[typ]
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := r.selectorCache.List(ctx, list, client.MatchingLabelsSelector{Selector: r.selector}); err != nil {
+			klog.ErrorS(err, "failed to list webhooks to inject CA.", "kind", gvk.Kind, "selector", r.selector)
+			lastErr = err
+			continue
+		}
+		for i := range list.Items {
+			if injected[typ].Has(list.Items[i].GetName()) {
+				continue
+			}
+			if err := r.inject(ctx, &list.Items[i], caPEM, typ); err != nil {
+				lastErr = err
+			}
+		}
+	}
+
+	return lastErr
+}
+
+// inject injects the CA into obj and updates it if changed.
+func (r *injector) inject(ctx context.Context, obj *unstructured.Unstructured, caPEM []byte, typ WebhookType) error {
+	if !obj.GetDeletionTimestamp().IsZero() {
+		return nil
+	}
+
+	kind := webhookKinds[typ].Kind
+	changed, err := injectCA(obj, caPEM, typ)
+	if err != nil {
+		klog.ErrorS(err, "unable to inject CA.", "kind", kind, "name", obj.GetName())
+		recordInjectionFailure(kind, obj.GetName())
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	if err := r.writer.Update(ctx, obj); err != nil {
+		klog.ErrorS(err, "failed to update webhook with CA.", "kind", kind, "name", obj.GetName())
+		recordInjectionFailure(kind, obj.GetName())
+		return err
+	}
+	klog.InfoS("CA injected.", "kind", kind, "name", obj.GetName())
+	return nil
+}
+
+// injectCA sets caBundle of obj to caPEM, and returns whether obj is changed.
+func injectCA(obj *unstructured.Unstructured, caPEM []byte, typ WebhookType) (bool, error) {
+	caBundle := base64.StdEncoding.EncodeToString(caPEM)
+
+	var path []string
+	switch typ {
+	case Validating, Mutating:
+		webhooks, found, err := unstructured.NestedSlice(obj.Object, "webhooks")
+		if err != nil {
+			return false, err
+		}
+		if !found {
+			return false, errors.New("`webhooks` field not found")
+		}
+
+		changed := false
+		for i, h := range webhooks {
+			hook, ok := h.(map[string]interface{})
+			if !ok {
+				return false, fmt.Errorf("webhook %d is not well-formed", i)
+			}
+			if current, _, _ := unstructured.NestedString(hook, "clientConfig", "caBundle"); current != caBundle {
+				if err := unstructured.SetNestedField(hook, caBundle, "clientConfig", "caBundle"); err != nil {
+					return false, err
+				}
+				changed = true
+			}
+			webhooks[i] = hook
+		}
+		return changed, unstructured.SetNestedSlice(obj.Object, webhooks, "webhooks")
+	case CRDConversion:
+		if _, found, err := unstructured.NestedMap(obj.Object, "spec", "conversion", "webhook", "clientConfig"); err != nil || !found {
+			return false, errors.New("`spec.conversion.webhook.clientConfig` field not found")
+		}
+		path = []string{"spec", "conversion", "webhook", "clientConfig", "caBundle"}
+	case APIService:
+		if _, found, err := unstructured.NestedMap(obj.Object, "spec"); err != nil || !found {
+			return false, errors.New("`spec` field not found")
+		}
+		path = []string{"spec", "caBundle"}
+	default:
+		return false, fmt.Errorf("not supported webhook type %d", typ)
+	}
+
+	if current, _, _ := unstructured.NestedString(obj.Object, path...); current == caBundle {
+		return false, nil
+	}
+	return true, unstructured.SetNestedField(obj.Object, caBundle, path...)
+}
@@ -0,0 +1,68 @@
+package cert
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestInjectCA(t *testing.T) {
+	caPEM := []byte("ca")
+	caBundle := base64.StdEncoding.EncodeToString(caPEM)
+
+	tests := []struct {
+		name        string
+		typ         WebhookType
+		obj         map[string]interface{}
+		path        []string
+		wantChanged bool
+		wantErr     bool
+	}{
+		{
+			name:        "1",
+			typ:         CRDConversion,
+			obj:         map[string]interface{}{"spec": map[string]interface{}{"conversion": map[string]interface{}{"webhook": map[string]interface{}{"clientConfig": map[string]interface{}{}}}}},
+			path:        []string{"spec", "conversion", "webhook", "clientConfig", "caBundle"},
+			wantChanged: true,
+		},
+		{
+			name:        "apiservice",
+			typ:         APIService,
+			obj:         map[string]interface{}{"spec": map[string]interface{}{"caBundle": "old"}},
+			path:        []string{"spec", "caBundle"},
+			wantChanged: true,
+		},
+		{
+			name: "not changed",
+			typ:  APIService,
+			obj:  map[string]interface{}{"spec": map[string]interface{}{"caBundle": caBundle}},
+			path: []string{"spec", "caBundle"},
+		},
+		{
+			name:    "crd without conversion webhook",
+			typ:     CRDConversion,
+			obj:     map[string]interface{}{"spec": map[string]interface{}{"conversion": map[string]interface{}{"strategy": "None"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: tt.obj}
+			changed, err := injectCA(obj, caPEM, tt.typ)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("injectCA() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("injectCA() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if err != nil {
+				return
+			}
+			if got, _, _ := unstructured.NestedString(obj.Object, tt.path...); got != caBundle {
+				t.Errorf("caBundle = %q, want %q", got, caBundle)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Keys of the secret storing certificates, the same as the ones of open-policy-agent/cert-controller,
+// so secrets written by it are reused.
+const (
+	caCertKey = "ca.crt"
+	caKeyKey  = "ca.key"
+	certKey   = CertFile
+	keyKey    = "tls.key"
+)
+
+// keyPair is a certificate with its private key.
+type keyPair struct {
+	cert    *x509.Certificate
+	key     *rsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// createCA creates a self-signed CA valid between begin and end.
+func createCA(name, organization string, begin, end time.Time) (*keyPair, error) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0),
+		Subject: pkix.Name{
+			CommonName:   name,
+			Organization: []string{organization},
+		},
+		DNSNames:              []string{name},
+		NotBefore:             begin,
+		NotAfter:              end,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	return createKeyPair(template, nil)
+}
+
+// createServingCert creates a serving certificate for dnsNames signed by ca, the first name is the common name.
+func createServingCert(ca *keyPair, dnsNames []string, begin, end time.Time) (*keyPair, error) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName: dnsNames[0],
+		},
+		DNSNames:              dnsNames,
+		NotBefore:             begin,
+		NotAfter:              end,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	return createKeyPair(template, ca)
+}
+
+// createKeyPair creates a certificate from template, signed by parent or self-signed if parent is nil.
+func createKeyPair(template *x509.Certificate, parent *keyPair) (*keyPair, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, key.Public(), parentKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %w", err)
+	}
+
+	certBuf, keyBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := pem.Encode(certBuf, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+		return nil, fmt.Errorf("encoding certificate: %w", err)
+	}
+	if err := pem.Encode(keyBuf, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}); err != nil {
+		return nil, fmt.Errorf("encoding key: %w", err)
+	}
+
+	return &keyPair{cert: cert, key: key, certPEM: certBuf.Bytes(), keyPEM: keyBuf.Bytes()}, nil
+}
+
+// caFromSecret parses the CA stored in secret.
+func caFromSecret(secret *corev1.Secret) (*keyPair, error) {
+	certPEM, keyPEM := secret.Data[caCertKey], secret.Data[caKeyKey]
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return nil, fmt.Errorf("secret is not well-formed, missing %s or %s", caCertKey, caKeyKey)
+	}
+
+	cert, err := parseCert(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CA certificate: %w", err)
+	}
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, errors.New("bad CA key")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CA key: %w", err)
+	}
+
+	return &keyPair{cert: cert, key: key, certPEM: certPEM, keyPEM: keyPEM}, nil
+}
+
+// validCert checks the certificate and key are a pair, and the certificate is signed by ca and valid for
+// all dnsNames at the time given.
+func validCert(caPEM, certPEM, keyPEM []byte, dnsNames []string, at time.Time) error {
+	if len(caPEM) == 0 || len(certPEM) == 0 || len(keyPEM) == 0 {
+		return errors.New("empty certificate")
+	}
+
+	ca, err := parseCert(caPEM)
+	if err != nil {
+		return fmt.Errorf("parsing CA certificate: %w", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("building key pair: %w", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("parsing certificate: %w", err)
+	}
+
+	for _, name := range dnsNames {
+		if _, err := cert.Verify(x509.VerifyOptions{DNSName: name, Roots: roots, CurrentTime: at}); err != nil {
+			return fmt.Errorf("verifying certificate: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseCert parses the first certificate of certPEM.
+func parseCert(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -0,0 +1,45 @@
+package cert
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidCert(t *testing.T) {
+	now := time.Now()
+	ca, err := createCA(DefaultCAName, DefaultCAOrganization, now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serving, err := createServingCert(ca, []string{"kinitiras-webhook.kinitiras-system.svc", "webhook.example.com"}, now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := createCA("other-ca", DefaultCAOrganization, now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		caPEM    []byte
+		keyPEM   []byte
+		dnsNames []string
+		at       time.Time
+		wantErr  bool
+	}{
+		{name: "1", caPEM: ca.certPEM, keyPEM: serving.keyPEM, dnsNames: []string{"kinitiras-webhook.kinitiras-system.svc", "webhook.example.com"}, at: now},
+		{name: "missing dns name", caPEM: ca.certPEM, keyPEM: serving.keyPEM, dnsNames: []string{"kinitiras-webhook.default.svc"}, at: now, wantErr: true},
+		{name: "expires within lookahead", caPEM: ca.certPEM, keyPEM: serving.keyPEM, dnsNames: []string{"webhook.example.com"}, at: now.Add(2 * time.Hour), wantErr: true},
+		{name: "other ca", caPEM: other.certPEM, keyPEM: serving.keyPEM, dnsNames: []string{"webhook.example.com"}, at: now, wantErr: true},
+		{name: "other key", caPEM: ca.certPEM, keyPEM: ca.keyPEM, dnsNames: []string{"webhook.example.com"}, at: now, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validCert(tt.caPEM, serving.certPEM, tt.keyPEM, tt.dnsNames, tt.at); (err != nil) != tt.wantErr {
+				t.Errorf("validCert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package cert
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Values of the type label of certificate metrics.
+const (
+	certTypeCA      = "ca"
+	certTypeServing = "serving"
+)
+
+var (
+	// certExpiration is the NotAfter of the CA and serving certificate in the secret.
+	certExpiration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kinitiras_cert_expiration_timestamp_seconds",
+		Help: "Expiration of the self-signed certificates in unix seconds, by type of ca or serving.",
+	}, []string{"type"})
+	// certRotations counts certificates regenerated, including the first ones.
+	certRotations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kinitiras_cert_rotations_total",
+		Help: "Number of self-signed certificates generated, by type of ca or serving.",
+	}, []string{"type"})
+	// caBundleInjectionFailures counts failures to inject the CA, by the kind and name of the object.
+	caBundleInjectionFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kinitiras_ca_bundle_injection_failures_total",
+		Help: "Number of failures to inject the CA into caBundle, by kind and name of the webhook configuration, CRD or APIService.",
+	}, []string{"kind", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(certExpiration, certRotations, caBundleInjectionFailures)
+}
+
+func recordExpiration(certType string, notAfter time.Time) {
+	certExpiration.WithLabelValues(certType).Set(float64(notAfter.Unix()))
+}
+
+func recordRotation(certType string) {
+	certRotations.WithLabelValues(certType).Inc()
+}
+
+func recordInjectionFailure(kind, name string) {
+	caBundleInjectionFailures.WithLabelValues(kind, name).Inc()
+}
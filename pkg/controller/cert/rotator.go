@@ -1,21 +1,42 @@
 package cert
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
 
-	"github.com/open-policy-agent/cert-controller/pkg/rotator"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
+// Defaults of Options.
 const (
-	secretName     = "kinitiras-webhook-cert"
-	namespace      = "kinitiras-system"
-	caName         = "kinitiras-ca"
-	caOrganization = "kinitiras"
-	serviceName    = "kinitiras-webhook"
-	certDir        = "/tmp/k8s-webhook-server/serving-certs"
+	DefaultSecretName     = "kinitiras-webhook-cert"
+	DefaultNamespace      = "kinitiras-system"
+	DefaultCAName         = "kinitiras-ca"
+	DefaultCAOrganization = "kinitiras"
+	DefaultServiceName    = "kinitiras-webhook"
+	// DefaultValidity is the validity of generated certificates.
+	DefaultValidity = 10 * 365 * 24 * time.Hour
+	// DefaultLookahead is how long before expiration certificates are rotated.
+	DefaultLookahead = 90 * 24 * time.Hour
+
+	certDir = "/tmp/k8s-webhook-server/serving-certs"
+	// rotationCheckFrequency is how often certificates are checked for rotation.
+	rotationCheckFrequency = 12 * time.Hour
 )
 
 type Options struct {
@@ -25,16 +46,26 @@ type Options struct {
 	CAName         string
 	ServiceName    string
 	CAOrganization string
-	Webhooks       []WebhookInfo
+	// ExtraDNSNames are the DNS names of the serving certificate besides <ServiceName>.<Namespace>.svc.
+	ExtraDNSNames []string
+	// Validity is the validity of the generated CA and serving certificate.
+	Validity time.Duration
+	// Lookahead is how long before expiration certificates are rotated, it must be shorter than Validity.
+	Lookahead time.Duration
+	// Webhooks are the webhook configurations, CRDs and APIServices to inject the CA into.
+	Webhooks []WebhookInfo
+	// WebhookSelector selects more webhook configurations, CRDs and APIServices to inject the CA into by labels.
+	// Nil or empty selects none.
+	WebhookSelector labels.Selector
 }
 
 func (option *Options) Default() {
 	if option.Namespace == "" {
-		option.Namespace = namespace
+		option.Namespace = DefaultNamespace
 	}
 
 	if option.SecretName == "" {
-		option.SecretName = secretName
+		option.SecretName = DefaultSecretName
 	}
 
 	if option.CertDir == "" {
@@ -42,30 +73,43 @@ func (option *Options) Default() {
 	}
 
 	if option.CAOrganization == "" {
-		option.CAOrganization = caOrganization
+		option.CAOrganization = DefaultCAOrganization
 	}
 
 	if option.CAName == "" {
-		option.CAName = caName
+		option.CAName = DefaultCAName
 	}
 
 	if option.ServiceName == "" {
-		option.ServiceName = serviceName
+		option.ServiceName = DefaultServiceName
+	}
+
+	if option.Validity <= 0 {
+		option.Validity = DefaultValidity
+	}
+
+	if option.Lookahead <= 0 {
+		option.Lookahead = DefaultLookahead
 	}
 
 	for i := range option.Webhooks {
 		if option.Webhooks[i].Name == "" {
-			option.Webhooks[i].Name = serviceName
+			option.Webhooks[i].Name = DefaultServiceName
 		}
 	}
 }
 
+// DNSNames returns the DNS names of the serving certificate, the name of the service first.
+func (option *Options) DNSNames() []string {
+	return append([]string{fmt.Sprintf("%s.%s.svc", option.ServiceName, option.Namespace)}, option.ExtraDNSNames...)
+}
+
 type WebhookInfo struct {
 	Name string
 	Type WebhookType
 }
 
-//WebhookType is the type of webhook, either validating/mutating webhook, a CRD conversion webhook, or an extension API server
+// WebhookType is the type of webhook, either validating/mutating webhook, a CRD conversion webhook, or an extension API server
 type WebhookType int
 
 const (
@@ -79,56 +123,251 @@ const (
 	APIService
 )
 
+// webhookTypes are the supported webhook types in order.
+var webhookTypes = []WebhookType{Mutating, Validating, CRDConversion, APIService}
+
+var webhookKinds = map[WebhookType]schema.GroupVersionKind{
+	Validating:    {Group: "admissionregistration.k8s.io", Version: "v1", Kind: "ValidatingWebhookConfiguration"},
+	Mutating:      {Group: "admissionregistration.k8s.io", Version: "v1", Kind: "MutatingWebhookConfiguration"},
+	CRDConversion: {Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"},
+	APIService:    {Group: "apiregistration.k8s.io", Version: "v1", Kind: "APIService"},
+}
+
+// GroupVersionKind returns the kind of the webhook type, false if the type is not supported.
+func (t WebhookType) GroupVersionKind() (schema.GroupVersionKind, bool) {
+	gvk, ok := webhookKinds[t]
+	return gvk, ok
+}
+
+// ParseWebhookInfo parses a target to inject the CA into, in Kind/name format, e.g.
+// MutatingWebhookConfiguration/foo, CustomResourceDefinition/bars.example.com or APIService/v1.example.com.
+func ParseWebhookInfo(value string) (WebhookInfo, error) {
+	items := strings.Split(value, "/")
+	if len(items) != 2 || items[1] == "" {
+		return WebhookInfo{}, fmt.Errorf("invalid target %q, expects Kind/name", value)
+	}
+
+	for _, typ := range webhookTypes {
+		if webhookKinds[typ].Kind == items[0] {
+			return WebhookInfo{Name: items[1], Type: typ}, nil
+		}
+	}
+	return WebhookInfo{}, fmt.Errorf("invalid target %q, kind must be one of MutatingWebhookConfiguration, "+
+		"ValidatingWebhookConfiguration, CustomResourceDefinition and APIService", value)
+}
+
+// SetupCertRotator adds the rotator of self-signed certificates to mgr. The returned channel is closed once
+// certificates are written to CertDir and the CA is injected into webhooks.
 func SetupCertRotator(mgr manager.Manager, options Options) (chan struct{}, error) {
 	options.Default()
 
-	// Make sure certs are generated and valid if cert rotation is enabled.
-	setupFinished := make(chan struct{})
+	for _, webhook := range options.Webhooks {
+		if _, ok := webhook.Type.GroupVersionKind(); !ok {
+			return nil, fmt.Errorf("webhook %q: not supported webhook type %d", webhook.Name, webhook.Type)
+		}
+	}
+	if options.Lookahead >= options.Validity {
+		return nil, fmt.Errorf("lookahead %s must be shorter than validity %s", options.Lookahead, options.Validity)
+	}
+
 	klog.Info("setting up cert rotator")
-	err := rotator.AddRotator(mgr, &rotator.CertRotator{
-		SecretKey: types.NamespacedName{
-			Name:      options.SecretName,
-			Namespace: options.Namespace,
-		},
-		CertDir:        options.CertDir,
-		CAName:         options.CAName,
-		CAOrganization: options.CAOrganization,
-		DNSName:        fmt.Sprintf("%s.%s.svc", options.ServiceName, options.Namespace),
-		IsReady:        setupFinished,
-		Webhooks:       convertWebhooks(options.Webhooks),
+	// secrets are only cached in the namespace, webhooks are cluster scoped
+	namespacedCache, err := cache.New(mgr.GetConfig(), cache.Options{
+		Scheme:    mgr.GetScheme(),
+		Mapper:    mgr.GetRESTMapper(),
+		Namespace: options.Namespace,
 	})
 	if err != nil {
-		klog.Error(err, "unable to setup cert rotator")
+		return nil, fmt.Errorf("creating namespaced cache: %w", err)
+	}
+	if err := mgr.Add(namespacedCache); err != nil {
+		return nil, fmt.Errorf("registering namespaced cache: %w", err)
+	}
+
+	r := &rotator{
+		opts:     options,
+		reader:   namespacedCache,
+		writer:   mgr.GetClient(),
+		recorder: mgr.GetEventRecorderFor("kinitiras-cert-rotator"),
+		isReady:  make(chan struct{}),
+	}
+	if err := mgr.Add(r); err != nil {
+		klog.ErrorS(err, "unable to setup cert rotator.")
+		return nil, err
+	}
+	i := &injector{
+		cache:     namespacedCache,
+		writer:    mgr.GetClient(),
+		secretKey: r.secretKey(),
+		webhooks:  options.Webhooks,
+		injected:  &r.injected,
+	}
+	if options.WebhookSelector != nil && !options.WebhookSelector.Empty() {
+		// only selected objects are cached, instead of all CRDs of the cluster
+		selectorsByObject := cache.SelectorsByObject{}
+		for _, typ := range webhookTypes {
+			selectorsByObject[newWebhookObject(typ)] = cache.ObjectSelector{Label: options.WebhookSelector}
+		}
+		selectorCache, err := cache.New(mgr.GetConfig(), cache.Options{
+			Scheme:            mgr.GetScheme(),
+			Mapper:            mgr.GetRESTMapper(),
+			SelectorsByObject: selectorsByObject,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("creating cache of selected webhooks: %w", err)
+		}
+		if err := mgr.Add(selectorCache); err != nil {
+			return nil, fmt.Errorf("registering cache of selected webhooks: %w", err)
+		}
+		i.selector, i.selectorCache = options.WebhookSelector, selectorCache
+	}
+	if err := addInjector(mgr, i); err != nil {
 		return nil, err
 	}
 
-	return setupFinished, nil
+	return r.isReady, nil
 }
 
-func convertWebhooks(webhooks []WebhookInfo) []rotator.WebhookInfo {
-	result := make([]rotator.WebhookInfo, len(webhooks))
+// rotator keeps the CA and serving certificate in the secret valid, and signals readiness once the
+// certificates are mounted to the cert dir and the CA is injected.
+type rotator struct {
+	opts   Options
+	reader cache.Cache
+	writer client.Writer
+	// recorder records events of rotation on the secret
+	recorder record.EventRecorder
+	// isReady is closed once certificates are ready
+	isReady chan struct{}
+	// injected is set to 1 by the injector once the CA is injected
+	injected int32
+}
 
-	for i := range webhooks {
-		result[i] = rotator.WebhookInfo{
-			Name: webhooks[i].Name,
-			Type: convertWebhookType(webhooks[i].Type),
+var _ manager.Runnable = &rotator{}
+
+func (r *rotator) secretKey() types.NamespacedName {
+	return types.NamespacedName{Namespace: r.opts.Namespace, Name: r.opts.SecretName}
+}
+
+// Start implements manager.Runnable, it blocks until ctx is done.
+func (r *rotator) Start(ctx context.Context) error {
+	if !r.reader.WaitForCacheSync(ctx) {
+		return errors.New("failed waiting for cache of cert rotator to sync")
+	}
+
+	// certificates are bootstrapped before anything else, since the webhook server can not start without
+	klog.InfoS("starting cert rotator.", "secret", r.secretKey(), "dnsNames", r.opts.DNSNames())
+	if err := wait.ExponentialBackoff(wait.Backoff{Duration: 10 * time.Millisecond, Factor: 2, Jitter: 1, Steps: 10}, func() (bool, error) {
+		if err := r.refreshCertsIfNeeded(ctx); err != nil {
+			klog.ErrorS(err, "failed to refresh certs, will retry.")
+			return false, nil
 		}
+		return true, nil
+	}); err != nil {
+		return fmt.Errorf("bootstrapping certs: %w", err)
 	}
 
-	return result
+	go r.ensureReady(ctx)
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.refreshCertsIfNeeded(ctx); err != nil {
+			klog.ErrorS(err, "failed to rotate certs.")
+		}
+	}, rotationCheckFrequency)
+	return nil
 }
 
-func convertWebhookType(typ WebhookType) rotator.WebhookType {
-	switch typ {
-	case Validating:
-		return rotator.Validating
-	case Mutating:
-		return rotator.Mutating
-	case CRDConversion:
-		return rotator.CRDConversion
-	case APIService:
-		return rotator.APIService
-	default:
-		panic("not supported webhook type")
+// refreshCertsIfNeeded regenerates the CA and serving certificate if they expire within the lookahead, or
+// the serving certificate if it does not cover all DNS names.
+func (r *rotator) refreshCertsIfNeeded(ctx context.Context) error {
+	secret := &corev1.Secret{}
+	if err := r.reader.Get(ctx, r.secretKey(), secret); err != nil {
+		return fmt.Errorf("acquiring secret to update certificates: %w", err)
 	}
+
+	at := time.Now().Add(r.opts.Lookahead)
+	refreshCA := false
+	err := validCert(secret.Data[caCertKey], secret.Data[caCertKey], secret.Data[caKeyKey], []string{r.opts.CAName}, at)
+	if err != nil {
+		klog.InfoS("refreshing CA and server certs.", "reason", err.Error())
+		refreshCA = true
+	} else if err = validCert(secret.Data[caCertKey], secret.Data[certKey], secret.Data[keyKey], r.opts.DNSNames(), at); err != nil {
+		klog.InfoS("refreshing server certs.", "reason", err.Error())
+	} else {
+		klog.V(2).InfoS("no cert refresh needed.")
+		r.recordExpirations(secret.Data[caCertKey], secret.Data[certKey])
+		return nil
+	}
+
+	if err := r.refreshCerts(ctx, secret, refreshCA); err != nil {
+		r.recorder.Eventf(secret, corev1.EventTypeWarning, "CertRotationFailed", "Failed to rotate certificates: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *rotator) refreshCerts(ctx context.Context, secret *corev1.Secret, refreshCA bool) error {
+	now := time.Now()
+	begin, end := now.Add(-time.Hour), now.Add(r.opts.Validity)
+
+	var ca *keyPair
+	var err error
+	if refreshCA {
+		ca, err = createCA(r.opts.CAName, r.opts.CAOrganization, begin, end)
+	} else {
+		ca, err = caFromSecret(secret)
+	}
+	if err != nil {
+		return err
+	}
+	serving, err := createServingCert(ca, r.opts.DNSNames(), begin, end)
+	if err != nil {
+		return err
+	}
+
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data[caCertKey] = ca.certPEM
+	secret.Data[caKeyKey] = ca.keyPEM
+	secret.Data[certKey] = serving.certPEM
+	secret.Data[keyKey] = serving.keyPEM
+	if err := r.writer.Update(ctx, secret); err != nil {
+		return err
+	}
+
+	klog.InfoS("certs refreshed.", "secret", r.secretKey(), "refreshCA", refreshCA, "notAfter", serving.cert.NotAfter)
+	if refreshCA {
+		recordRotation(certTypeCA)
+	}
+	recordRotation(certTypeServing)
+	r.recordExpirations(ca.certPEM, serving.certPEM)
+	r.recorder.Eventf(secret, corev1.EventTypeNormal, "CertsRotated", "Rotated certificates for %s, CA rotated: %t, valid until %s",
+		strings.Join(r.opts.DNSNames(), ","), refreshCA, serving.cert.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+// recordExpirations records the expiration of the CA and serving certificate.
+func (r *rotator) recordExpirations(caPEM, certPEM []byte) {
+	for certType, data := range map[string][]byte{certTypeCA: caPEM, certTypeServing: certPEM} {
+		cert, err := parseCert(data)
+		if err != nil {
+			klog.ErrorS(err, "unable to parse certificate to record its expiration.", "type", certType)
+			continue
+		}
+		recordExpiration(certType, cert.NotAfter)
+	}
+}
+
+// ensureReady closes isReady once the certificate is mounted to the cert dir and the CA is injected.
+func (r *rotator) ensureReady(ctx context.Context) {
+	certFile := filepath.Join(r.opts.CertDir, CertFile)
+	if err := wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		_, err := os.Stat(certFile)
+		return err == nil && atomic.LoadInt32(&r.injected) == 1, nil
+	}, ctx.Done()); err != nil {
+		return
+	}
+
+	klog.InfoS("certs are mounted and CA is injected.", "certDir", r.opts.CertDir)
+	close(r.isReady)
 }
@@ -0,0 +1,146 @@
+package cert
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeCache reads objects from a client, it is always synced.
+type fakeCache struct {
+	cache.Cache
+	reader client.Reader
+}
+
+func (c fakeCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return c.reader.Get(ctx, key, obj)
+}
+
+func (c fakeCache) WaitForCacheSync(context.Context) bool {
+	return true
+}
+
+func newTestRotator(t *testing.T, opts Options) (*rotator, client.Client, *record.FakeRecorder) {
+	opts.CertDir = t.TempDir()
+	opts.Default()
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: opts.Namespace, Name: opts.SecretName}}
+	c := fake.NewClientBuilder().WithObjects(secret).Build()
+	recorder := record.NewFakeRecorder(10)
+
+	return &rotator{
+		opts:     opts,
+		reader:   fakeCache{reader: c},
+		writer:   c,
+		recorder: recorder,
+		isReady:  make(chan struct{}),
+	}, c, recorder
+}
+
+func getSecret(t *testing.T, c client.Client, r *rotator) *corev1.Secret {
+	t.Helper()
+
+	secret := &corev1.Secret{}
+	if err := c.Get(context.Background(), r.secretKey(), secret); err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+func TestRotator_Start(t *testing.T) {
+	r, c, recorder := newTestRotator(t, Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Start(ctx)
+	}()
+
+	// certificates are bootstrapped into the empty secret
+	var secret *corev1.Secret
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		secret = getSecret(t, c, r)
+		return len(secret.Data[certKey]) != 0, nil
+	}); err != nil {
+		t.Fatalf("certs are not bootstrapped: %v", err)
+	}
+	at := time.Now().Add(r.opts.Lookahead)
+	if err := validCert(secret.Data[caCertKey], secret.Data[certKey], secret.Data[keyKey], r.opts.DNSNames(), at); err != nil {
+		t.Errorf("invalid serving cert: %v", err)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal CertsRotated") {
+		t.Errorf("event = %q, want CertsRotated", event)
+	}
+
+	// ready once the certificate is mounted and the CA is injected
+	select {
+	case <-r.isReady:
+		t.Fatalf("ready before certs are mounted")
+	default:
+	}
+	if err := os.WriteFile(filepath.Join(r.opts.CertDir, CertFile), secret.Data[certKey], 0600); err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&r.injected, 1)
+	select {
+	case <-r.isReady:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("not ready after certs are mounted and CA is injected")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+}
+
+func TestRotator_RefreshCertsIfNeeded(t *testing.T) {
+	r, c, _ := newTestRotator(t, Options{Validity: 2 * time.Hour, Lookahead: time.Hour})
+	ctx := context.Background()
+	if err := r.refreshCertsIfNeeded(ctx); err != nil {
+		t.Fatal(err)
+	}
+	bootstrapped := getSecret(t, c, r)
+
+	// valid certificates are kept
+	if err := r.refreshCertsIfNeeded(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := getSecret(t, c, r); got.ResourceVersion != bootstrapped.ResourceVersion {
+		t.Errorf("secret updated for valid certs")
+	}
+
+	// the serving certificate is rotated when it misses a DNS name, the CA is kept
+	r.opts.ExtraDNSNames = []string{"webhook.example.com"}
+	if err := r.refreshCertsIfNeeded(ctx); err != nil {
+		t.Fatal(err)
+	}
+	renamed := getSecret(t, c, r)
+	if !bytes.Equal(renamed.Data[caCertKey], bootstrapped.Data[caCertKey]) {
+		t.Errorf("CA rotated for a new DNS name")
+	}
+	if bytes.Equal(renamed.Data[certKey], bootstrapped.Data[certKey]) {
+		t.Errorf("serving cert not rotated for a new DNS name")
+	}
+
+	// both are rotated when they expire within the lookahead
+	r.opts.Lookahead = 3 * time.Hour
+	if err := r.refreshCertsIfNeeded(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expired := getSecret(t, c, r)
+	if bytes.Equal(expired.Data[caCertKey], renamed.Data[caCertKey]) || bytes.Equal(expired.Data[certKey], renamed.Data[certKey]) {
+		t.Errorf("certs not rotated when expiring within the lookahead")
+	}
+}
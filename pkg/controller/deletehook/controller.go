@@ -0,0 +1,373 @@
+package deletehook
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// Finalizer holds the deletion of an object until all delete hook policies selecting it pass.
+	// It is added to objects by override policies, e.g. a plaintext operation on CREATE.
+	Finalizer = "kinitiras.kcloudlabs.io/delete-hook"
+	// PolicyAnnotation marks a ClusterValidatePolicy as a delete hook policy when set to "true".
+	// Delete hook policies do not reject DELETE requests, they hold the Finalizer of the object
+	// being deleted instead, until they pass.
+	PolicyAnnotation = "kinitiras.kcloudlabs.io/delete-hook"
+	// DeletedByAnnotation records the user of the DELETE request of an object held by Finalizer, as json of
+	// UserInfo without extra. Delete hook policies are checked without the request, so user selectors of the
+	// policies are matched against it.
+	DeletedByAnnotation = "kinitiras.kcloudlabs.io/deleted-by"
+	// DependentsAnnotation lists resources in resource[.group] format on an OverridePolicy or ClusterOverridePolicy,
+	// e.g. "pods,persistentvolumeclaims". Changes of labels and annotations made by the policy on DELETE are applied
+	// to the objects of the resources owned by the object being deleted as well.
+	DependentsAnnotation = "kinitiras.kcloudlabs.io/delete-hook-dependents"
+
+	// defaultRetryPeriod is the period to check delete hook policies again when they do not pass.
+	defaultRetryPeriod = 10 * time.Second
+	// defaultResyncPeriod is the period to list objects held by Finalizer.
+	defaultResyncPeriod = time.Minute
+	// resyncPageSize is the number of objects listed in a request on resync.
+	resyncPageSize = 500
+	// maxPendingRetries is the number of retries to wait for the deletion of an object to start,
+	// the DELETE request may be rejected by another admission webhook.
+	maxPendingRetries = 5
+	// deletedByGracePeriod is the period to wait for DeletedByAnnotation to be recorded by the replica which served
+	// the DELETE request. Objects deleted without it, e.g. while the webhook was down, are checked afterwards with
+	// user selectors of delete hook policies matched against an anonymous user.
+	deletedByGracePeriod = time.Minute
+)
+
+// IsHookPolicy returns whether policy is a delete hook policy.
+func IsHookPolicy(policy metav1.Object) bool {
+	return policy.GetAnnotations()[PolicyAnnotation] == "true"
+}
+
+// HasFinalizer returns whether obj is held by Finalizer.
+func HasFinalizer(obj metav1.Object) bool {
+	for _, finalizer := range obj.GetFinalizers() {
+		if finalizer == Finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// Hook refers to an object being deleted.
+type Hook struct {
+	Resource  schema.GroupVersionResource
+	Namespace string
+	Name      string
+}
+
+// Metadata contains changes of labels and annotations made by override policies on DELETE.
+// A nil value removes the key.
+type Metadata struct {
+	Labels      map[string]*string `json:"labels,omitempty"`
+	Annotations map[string]*string `json:"annotations,omitempty"`
+	// Dependents are changes of dependents of the object by their resource, which are applied to the objects
+	// owned by the object being deleted. They are not part of the patch of the object.
+	Dependents map[schema.GroupResource]*Metadata `json:"-"`
+}
+
+// Empty returns whether there is no change.
+func (m *Metadata) Empty() bool {
+	return m == nil || len(m.Labels) == 0 && len(m.Annotations) == 0 && len(m.Dependents) == 0
+}
+
+// Merge returns the changes of m followed by the ones of other, other wins for the same key.
+// Both of them are not changed.
+func (m *Metadata) Merge(other *Metadata) *Metadata {
+	ret := &Metadata{}
+	for _, item := range []*Metadata{m, other} {
+		if item == nil {
+			continue
+		}
+		ret.Labels = mergeChanges(ret.Labels, item.Labels)
+		ret.Annotations = mergeChanges(ret.Annotations, item.Annotations)
+		for resource, changes := range item.Dependents {
+			if ret.Dependents == nil {
+				ret.Dependents = map[schema.GroupResource]*Metadata{}
+			}
+			ret.Dependents[resource] = ret.Dependents[resource].Merge(changes)
+		}
+	}
+	return ret
+}
+
+func mergeChanges(dst, src map[string]*string) map[string]*string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]*string, len(src))
+	}
+	for key, value := range src {
+		dst[key] = value
+	}
+	return dst
+}
+
+// ResourcesFunc returns the resources selected by delete hook policies, whose objects may be held by Finalizer.
+type ResourcesFunc func() ([]schema.GroupVersionResource, error)
+
+// CheckFunc applies delete hook policies selecting the object being deleted, and returns
+// the reason to keep holding the deletion, or empty if all of them pass.
+type CheckFunc func(ctx context.Context, hook Hook, obj *unstructured.Unstructured) (string, error)
+
+// Options contains settings of the delete hook controller.
+type Options struct {
+	// RetryPeriod is the period to check delete hook policies again when they do not pass.
+	// Defaults to 10s.
+	RetryPeriod time.Duration
+	// Resources returns the resources listed on resync. Objects are only enqueued by Enqueue if it is nil.
+	Resources ResourcesFunc
+	// ResyncPeriod is the period to list objects being deleted and held by Finalizer, which are enqueued
+	// after a restart, or when the DELETE request was served by another replica. Defaults to 1m.
+	ResyncPeriod time.Duration
+	// RESTMapper maps resources of dependents to their preferred version. Changes of dependents are
+	// discarded if it is nil.
+	RESTMapper meta.RESTMapper
+}
+
+// Controller applies delete hooks to objects being deleted. Since an object can not be patched by
+// the admission webhook of its DELETE request, the controller
+//   - applies changes of labels and annotations made by override policies on DELETE to the object and its
+//     dependents, and records the user deleting the object in DeletedByAnnotation, and
+//   - removes the Finalizer when delete hook policies pass, checking them again periodically otherwise.
+//
+// Objects being deleted and held by the Finalizer are listed periodically as well, so they are not left
+// behind by a restart. Changes of metadata are kept in memory and lost by a restart.
+type Controller struct {
+	client dynamic.Interface
+	check  CheckFunc
+	opts   Options
+	queue  workqueue.RateLimitingInterface
+
+	mu       sync.Mutex
+	metadata map[Hook]*Metadata
+}
+
+var _ manager.Runnable = &Controller{}
+
+// NewController builds a Controller. Start it by manager.
+func NewController(client dynamic.Interface, check CheckFunc, opts Options) *Controller {
+	if opts.RetryPeriod <= 0 {
+		opts.RetryPeriod = defaultRetryPeriod
+	}
+	if opts.ResyncPeriod <= 0 {
+		opts.ResyncPeriod = defaultResyncPeriod
+	}
+
+	return &Controller{
+		client:   client,
+		check:    check,
+		opts:     opts,
+		queue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "delete-hook"),
+		metadata: map[Hook]*Metadata{},
+	}
+}
+
+// Enqueue schedules delete hooks of an object being deleted, metadata is applied to the object once.
+// Metadata of the same object enqueued before it is applied is merged.
+func (c *Controller) Enqueue(hook Hook, metadata *Metadata) {
+	if !metadata.Empty() {
+		c.mu.Lock()
+		c.metadata[hook] = c.metadata[hook].Merge(metadata)
+		c.mu.Unlock()
+	}
+
+	klog.V(4).InfoS("enqueue delete hook.", "resource", hook.Resource, "namespace", hook.Namespace, "name", hook.Name)
+	c.queue.Add(hook)
+}
+
+func (c *Controller) getMetadata(hook Hook) *Metadata {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.metadata[hook]
+}
+
+func (c *Controller) forget(hook Hook) {
+	c.mu.Lock()
+	delete(c.metadata, hook)
+	c.mu.Unlock()
+	c.queue.Forget(hook)
+}
+
+// Start implements manager.Runnable, it blocks until ctx is done.
+func (c *Controller) Start(ctx context.Context) error {
+	defer c.queue.ShutDown()
+
+	klog.InfoS("starting delete hook controller.")
+	go wait.UntilWithContext(ctx, c.worker, time.Second)
+	if c.opts.Resources != nil {
+		go wait.UntilWithContext(ctx, c.resync, c.opts.ResyncPeriod)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// resync enqueues objects of Resources which are being deleted and held by Finalizer.
+func (c *Controller) resync(ctx context.Context) {
+	resources, err := c.opts.Resources()
+	if err != nil {
+		klog.ErrorS(err, "failed to get resources of delete hook policies.")
+		return
+	}
+
+	for _, gvr := range resources {
+		opts := metav1.ListOptions{Limit: resyncPageSize}
+		for {
+			list, err := c.client.Resource(gvr).List(ctx, opts)
+			if err != nil {
+				klog.ErrorS(err, "failed to list objects held by delete hooks.", "resource", gvr)
+				break
+			}
+			for i := range list.Items {
+				obj := &list.Items[i]
+				if obj.GetDeletionTimestamp() != nil && HasFinalizer(obj) {
+					c.queue.Add(Hook{Resource: gvr, Namespace: obj.GetNamespace(), Name: obj.GetName()})
+				}
+			}
+			if opts.Continue = list.GetContinue(); opts.Continue == "" {
+				break
+			}
+		}
+	}
+}
+
+func (c *Controller) worker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	item, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(item)
+
+	hook := item.(Hook)
+	if err := c.sync(ctx, hook); err != nil {
+		klog.ErrorS(err, "failed to sync delete hook, will retry.", "resource", hook.Resource, "namespace", hook.Namespace, "name", hook.Name)
+		c.queue.AddRateLimited(hook)
+	}
+
+	return true
+}
+
+func (c *Controller) sync(ctx context.Context, hook Hook) error {
+	resource := c.client.Resource(hook.Resource).Namespace(hook.Namespace)
+	obj, err := resource.Get(ctx, hook.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			c.forget(hook)
+			return nil
+		}
+		return err
+	}
+
+	if obj.GetDeletionTimestamp() == nil {
+		// the DELETE request is still in admission, or it was rejected. The hook is dropped since the object
+		// is not being deleted, and it is enqueued again by resync once the object is being deleted.
+		if c.queue.NumRequeues(hook) >= maxPendingRetries {
+			klog.V(2).InfoS("object is not being deleted, drop delete hook.", "resource", hook.Resource, "namespace", hook.Namespace, "name", hook.Name)
+			c.forget(hook)
+			return nil
+		}
+		c.queue.AddRateLimited(hook)
+		return nil
+	}
+
+	if metadata := c.getMetadata(hook); !metadata.Empty() {
+		if len(metadata.Labels) != 0 || len(metadata.Annotations) != 0 {
+			data, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+			if err != nil {
+				return err
+			}
+			if obj, err = resource.Patch(ctx, hook.Name, types.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+				return err
+			}
+			klog.InfoS("applied metadata of override policies to object being deleted.", "resource", hook.Resource, "object", klog.KObj(obj))
+		}
+		if err := c.applyDependents(ctx, obj, metadata.Dependents); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		// metadata enqueued since is applied by the next sync
+		if c.metadata[hook] == metadata {
+			delete(c.metadata, hook)
+		}
+		c.mu.Unlock()
+	}
+
+	if !HasFinalizer(obj) {
+		c.forget(hook)
+		return nil
+	}
+
+	if _, ok := obj.GetAnnotations()[DeletedByAnnotation]; !ok && time.Since(obj.GetDeletionTimestamp().Time) < deletedByGracePeriod {
+		klog.V(4).InfoS("wait for the user deleting the object to be recorded.", "resource", hook.Resource, "object", klog.KObj(obj))
+		c.queue.Forget(hook)
+		c.queue.AddAfter(hook, c.opts.RetryPeriod)
+		return nil
+	}
+
+	reason, err := c.check(ctx, hook, obj)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		klog.V(2).InfoS("delete hook policies do not pass yet.", "resource", hook.Resource, "object", klog.KObj(obj), "reason", reason)
+		c.queue.Forget(hook)
+		c.queue.AddAfter(hook, c.opts.RetryPeriod)
+		return nil
+	}
+
+	if err := c.removeFinalizer(ctx, hook, obj); err != nil {
+		return err
+	}
+	klog.InfoS("delete hook policies passed, removed finalizer.", "resource", hook.Resource, "object", klog.KObj(obj))
+	c.forget(hook)
+	return nil
+}
+
+// removeFinalizer removes Finalizer from obj, the patch fails if obj has changed since it was checked.
+func (c *Controller) removeFinalizer(ctx context.Context, hook Hook, obj *unstructured.Unstructured) error {
+	finalizers := make([]string, 0, len(obj.GetFinalizers()))
+	for _, finalizer := range obj.GetFinalizers() {
+		if finalizer != Finalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+
+	data, err := json.Marshal([]map[string]interface{}{
+		{"op": "test", "path": "/metadata/resourceVersion", "value": obj.GetResourceVersion()},
+		{"op": "replace", "path": "/metadata/finalizers", "value": finalizers},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.Resource(hook.Resource).Namespace(hook.Namespace).Patch(ctx, hook.Name, types.JSONPatchType, data, metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package deletehook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func TestController_Resync(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	newConfigMap := func(name string, deleting bool) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, ResourceVersion: "1", Finalizers: []string{Finalizer}},
+		}
+		if deleting {
+			now := metav1.Now()
+			cm.DeletionTimestamp = &now
+			cm.Annotations = map[string]string{DeletedByAnnotation: `{"username":"admin"}`}
+		}
+		return cm
+	}
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleDynamicClient(scheme, newConfigMap("passed", true), newConfigMap("waiting", true), newConfigMap("alive", false))
+
+	checked := map[string]int{}
+	c := NewController(client, func(_ context.Context, hook Hook, _ *unstructured.Unstructured) (string, error) {
+		checked[hook.Name]++
+		if hook.Name == "waiting" {
+			return "teardown is not done", nil
+		}
+		return "", nil
+	}, Options{Resources: func() ([]schema.GroupVersionResource, error) {
+		return []schema.GroupVersionResource{gvr}, nil
+	}})
+	defer c.queue.ShutDown()
+
+	ctx := context.Background()
+	c.resync(ctx)
+	if got := c.queue.Len(); got != 2 {
+		t.Fatalf("resync enqueued %d objects, want objects being deleted only", got)
+	}
+	for i := 0; i < 2; i++ {
+		c.processNextItem(ctx)
+	}
+
+	for name, want := range map[string]bool{"passed": false, "waiting": true, "alive": true} {
+		obj, err := client.Resource(gvr).Namespace("default").Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := HasFinalizer(obj); got != want {
+			t.Errorf("finalizer of %s = %v, want %v", name, got, want)
+		}
+	}
+	if checked["passed"] != 1 || checked["waiting"] != 1 || checked["alive"] != 0 {
+		t.Errorf("checked = %v, want objects being deleted checked once", checked)
+	}
+
+	// an object held by delete hooks is enqueued again by resync, e.g. after a restart
+	c.resync(ctx)
+	if got := c.queue.Len(); got != 1 {
+		t.Errorf("resync enqueued %d objects, want the object still held", got)
+	}
+}
+
+func TestController_DeletedByAndDependents(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	newConfigMap := func(name string, deletedAt time.Time, owner types.UID) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: types.UID(name), ResourceVersion: "1"},
+		}
+		if !deletedAt.IsZero() {
+			deletionTimestamp := metav1.NewTime(deletedAt)
+			cm.DeletionTimestamp = &deletionTimestamp
+			cm.Finalizers = []string{Finalizer}
+		}
+		if owner != "" {
+			cm.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "ConfigMap", Name: string(owner), UID: owner}}
+		}
+		return cm
+	}
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	client := fake.NewSimpleDynamicClient(scheme,
+		newConfigMap("owner", now, ""), newConfigMap("dependent", time.Time{}, "owner"), newConfigMap("other", time.Time{}, "another"),
+		newConfigMap("unrecorded", now, ""), newConfigMap("abandoned", now.Add(-time.Hour), ""))
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+
+	users := map[string]string{}
+	c := NewController(client, func(_ context.Context, hook Hook, obj *unstructured.Unstructured) (string, error) {
+		users[hook.Name] = obj.GetAnnotations()[DeletedByAnnotation]
+		return "", nil
+	}, Options{RESTMapper: mapper})
+	defer c.queue.ShutDown()
+
+	deletedBy, err := DeletedBy(authenticationv1.UserInfo{Username: "admin", Extra: map[string]authenticationv1.ExtraValue{"scopes": {"a"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	teardown := "started"
+	c.Enqueue(Hook{Resource: gvr, Namespace: "default", Name: "owner"}, &Metadata{
+		Dependents: map[schema.GroupResource]*Metadata{gvr.GroupResource(): {Annotations: map[string]*string{"teardown": &teardown}}},
+	})
+	c.Enqueue(Hook{Resource: gvr, Namespace: "default", Name: "owner"}, deletedBy)
+	c.Enqueue(Hook{Resource: gvr, Namespace: "default", Name: "unrecorded"}, nil)
+	c.Enqueue(Hook{Resource: gvr, Namespace: "default", Name: "abandoned"}, nil)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		c.processNextItem(ctx)
+	}
+
+	want := map[string]string{"owner": `{"username":"admin"}`, "abandoned": ""}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf("checked users = %v, want %v", users, want)
+	}
+	for name, want := range map[string]string{"dependent": "started", "other": ""} {
+		obj, err := client.Resource(gvr).Namespace("default").Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := obj.GetAnnotations()["teardown"]; got != want {
+			t.Errorf("annotation of %s = %q, want %q", name, got, want)
+		}
+	}
+}
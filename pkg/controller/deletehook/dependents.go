@@ -0,0 +1,92 @@
+package deletehook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// DependentsFromAnnotations returns the resources of dependents stored in annotations.
+func DependentsFromAnnotations(annotations map[string]string) ([]schema.GroupResource, error) {
+	val, ok := annotations[DependentsAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	var resources []schema.GroupResource
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || strings.Contains(item, "/") {
+			return nil, fmt.Errorf("invalid annotation %s: invalid resource(%v), should be in resource[.group] format", DependentsAnnotation, item)
+		}
+		resources = append(resources, schema.ParseGroupResource(item))
+	}
+
+	return resources, nil
+}
+
+// applyDependents applies changes of dependents to the objects of their resources owned by obj. Dependents of
+// a namespaced object are in its namespace, the ones of a cluster scoped object are looked up in all namespaces.
+func (c *Controller) applyDependents(ctx context.Context, obj *unstructured.Unstructured, dependents map[schema.GroupResource]*Metadata) error {
+	if len(dependents) == 0 {
+		return nil
+	}
+	if c.opts.RESTMapper == nil {
+		klog.V(2).InfoS("discard changes of dependents without rest mapper.", "object", klog.KObj(obj))
+		return nil
+	}
+
+	for resource, metadata := range dependents {
+		if metadata.Empty() {
+			continue
+		}
+		gvr, err := c.opts.RESTMapper.ResourceFor(resource.WithVersion(""))
+		if err != nil {
+			klog.V(2).InfoS("skip unknown resource of dependents.", "object", klog.KObj(obj), "resource", resource, "err", err)
+			continue
+		}
+		data, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+		if err != nil {
+			return err
+		}
+
+		opts := metav1.ListOptions{Limit: resyncPageSize}
+		for {
+			list, err := c.client.Resource(gvr).Namespace(obj.GetNamespace()).List(ctx, opts)
+			if err != nil {
+				return fmt.Errorf("failed to list dependents of %s: %w", gvr, err)
+			}
+			for i := range list.Items {
+				dependent := &list.Items[i]
+				if !isOwnedBy(dependent, obj) {
+					continue
+				}
+				if _, err := c.client.Resource(gvr).Namespace(dependent.GetNamespace()).Patch(ctx, dependent.GetName(), types.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+					return fmt.Errorf("failed to patch dependent %s %s: %w", gvr, klog.KObj(dependent), err)
+				}
+				klog.InfoS("applied metadata of override policies to dependent of object being deleted.", "object", klog.KObj(obj), "resource", gvr, "dependent", klog.KObj(dependent))
+			}
+			if opts.Continue = list.GetContinue(); opts.Continue == "" {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func isOwnedBy(obj, owner metav1.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package deletehook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MetadataChanges returns the changes of labels and annotations from before to after,
+// annotations for which ignoreAnnotation returns true are left out.
+func MetadataChanges(before, after metav1.Object, ignoreAnnotation func(key string) bool) *Metadata {
+	annotations := diff(before.GetAnnotations(), after.GetAnnotations())
+	for key := range annotations {
+		if ignoreAnnotation != nil && ignoreAnnotation(key) {
+			delete(annotations, key)
+		}
+	}
+
+	return &Metadata{
+		Labels:      diff(before.GetLabels(), after.GetLabels()),
+		Annotations: annotations,
+	}
+}
+
+func diff(before, after map[string]string) map[string]*string {
+	changes := map[string]*string{}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changes[key] = nil
+		}
+	}
+	for key, value := range after {
+		if old, ok := before[key]; !ok || old != value {
+			value := value
+			changes[key] = &value
+		}
+	}
+
+	return changes
+}
+
+// DeletedBy returns the changes recording user in DeletedByAnnotation. Extra of user is left out,
+// since it may hold details of credentials, e.g. scopes of tokens.
+func DeletedBy(user authenticationv1.UserInfo) (*Metadata, error) {
+	data, err := json.Marshal(authenticationv1.UserInfo{Username: user.Username, UID: user.UID, Groups: user.Groups})
+	if err != nil {
+		return nil, err
+	}
+
+	value := string(data)
+	return &Metadata{Annotations: map[string]*string{DeletedByAnnotation: &value}}, nil
+}
+
+// DeletedByFromAnnotations returns the user recorded in DeletedByAnnotation, and whether it is recorded.
+func DeletedByFromAnnotations(annotations map[string]string) (authenticationv1.UserInfo, bool, error) {
+	var user authenticationv1.UserInfo
+	val, ok := annotations[DeletedByAnnotation]
+	if !ok {
+		return user, false, nil
+	}
+	if err := json.Unmarshal([]byte(val), &user); err != nil {
+		return user, false, fmt.Errorf("invalid annotation %s: %w", DeletedByAnnotation, err)
+	}
+	return user, true, nil
+}
@@ -0,0 +1,42 @@
+package deletehook
+
+import (
+	"encoding/json"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMetadataChanges(t *testing.T) {
+	before := &metav1.ObjectMeta{
+		Labels:      map[string]string{"app": "db", "tier": "data"},
+		Annotations: map[string]string{"a": "1"},
+	}
+	after := &metav1.ObjectMeta{
+		Labels:      map[string]string{"app": "db", "tier": "backup"},
+		Annotations: map[string]string{"a": "1", "applied": "p", "teardown": "started"},
+	}
+
+	tests := []struct {
+		name   string
+		before metav1.Object
+		after  metav1.Object
+		want   string
+	}{
+		{name: "1", before: before, after: after, want: `{"labels":{"tier":"backup"},"annotations":{"teardown":"started"}}`},
+		{name: "2", before: after, after: before, want: `{"labels":{"tier":"data"},"annotations":{"teardown":null}}`},
+		{name: "3", before: after, after: after, want: `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(MetadataChanges(tt.before, tt.after, func(key string) bool { return key == "applied" }))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("MetadataChanges() = %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package webhookconfig
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// ManagedWebhooksAnnotation lists the names of webhooks in a webhook configuration whose rules and namespaceSelector
+// are managed by the controller, separated by comma. Other webhooks of the configuration are left untouched.
+const ManagedWebhooksAnnotation = "kinitiras.kcloudlabs.io/managed-webhooks"
+
+// PolicyWebhookAnnotation names the managed webhook of a webhook configuration which policies are routed to, without
+// the namespaceSelector of the allowlist since policies are never allowlisted, and with an empty objectSelector.
+// Without it, policies are routed to all managed webhooks and namespaces on the allowlist are not excluded by
+// namespaceSelector.
+const PolicyWebhookAnnotation = "kinitiras.kcloudlabs.io/policy-webhook"
+
+// SubresourceWebhookAnnotation names the managed webhook of a webhook configuration which subresources selected by
+// policies are routed to. Objects of subresource requests, e.g. Scale and PodExecOptions, have no labels, so such
+// requests never match a webhook with an objectSelector. Without it, subresources are routed to all managed webhooks.
+const SubresourceWebhookAnnotation = "kinitiras.kcloudlabs.io/subresource-webhook"
+
+const (
+	// syncKey is the only key of the queue, all changes lead to a full sync.
+	syncKey = "sync"
+	// resyncPeriod makes selectors of kinds installed after the policies eventually routed.
+	resyncPeriod = 5 * time.Minute
+)
+
+// Options contains settings of the webhook configuration controller.
+type Options struct {
+	// MutatingConfig is the name of the MutatingWebhookConfiguration owned by kinitiras.
+	MutatingConfig string
+	// ValidatingConfig is the name of the ValidatingWebhookConfiguration owned by kinitiras.
+	ValidatingConfig string
+	// OverridePolicies are the stores of OverridePolicies and ClusterOverridePolicies.
+	OverridePolicies []cache.Store
+	// ValidatePolicies are the stores of ClusterValidatePolicies.
+	ValidatePolicies []cache.Store
+	// NamespaceSelector is set to the managed webhooks of the configurations, nil means all namespaces.
+	NamespaceSelector *metav1.LabelSelector
+}
+
+// Controller keeps the rules of kinitiras webhook configurations narrowed to the resources and
+// operations selected by policies, so apiserver only calls the webhook when a policy may apply.
+// It also excludes namespaces on the allowlist by namespaceSelector.
+type Controller struct {
+	client client.Client
+	mapper meta.RESTMapper
+	opts   Options
+	queue  workqueue.RateLimitingInterface
+
+	mu                sync.Mutex
+	namespaceSelector *metav1.LabelSelector
+}
+
+var _ manager.Runnable = &Controller{}
+
+// NewController builds a Controller. Add its EventHandler to policy informers and start it by manager.
+func NewController(c client.Client, mapper meta.RESTMapper, opts Options) *Controller {
+	controller := &Controller{
+		client: c,
+		mapper: mapper,
+		opts:   opts,
+		queue:  workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "webhook-configuration"),
+	}
+	controller.SetNamespaceSelector(opts.NamespaceSelector)
+
+	return controller
+}
+
+// SetNamespaceSelector replaces the namespaceSelector of managed webhooks, e.g. when the allowlist is reloaded.
+func (c *Controller) SetNamespaceSelector(selector *metav1.LabelSelector) {
+	if selector == nil {
+		// defaulted by apiserver
+		selector = &metav1.LabelSelector{}
+	}
+
+	c.mu.Lock()
+	c.namespaceSelector = selector
+	c.mu.Unlock()
+	c.queue.Add(syncKey)
+}
+
+func (c *Controller) getNamespaceSelector() *metav1.LabelSelector {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.namespaceSelector.DeepCopy()
+}
+
+// EventHandler returns the handler to add to policy informers.
+func (c *Controller) EventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { c.queue.Add(syncKey) },
+		UpdateFunc: func(_, _ interface{}) { c.queue.Add(syncKey) },
+		DeleteFunc: func(interface{}) { c.queue.Add(syncKey) },
+	}
+}
+
+// Start implements manager.Runnable, it blocks until ctx is done.
+func (c *Controller) Start(ctx context.Context) error {
+	defer c.queue.ShutDown()
+
+	klog.InfoS("starting webhook configuration controller.")
+	go wait.UntilWithContext(ctx, func(context.Context) { c.queue.Add(syncKey) }, resyncPeriod)
+	go wait.UntilWithContext(ctx, c.worker, time.Second)
+
+	<-ctx.Done()
+	return nil
+}
+
+func (c *Controller) worker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(ctx); err != nil {
+		klog.ErrorS(err, "failed to sync webhook configurations, will retry.")
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *Controller) sync(ctx context.Context) error {
+	selector := c.getNamespaceSelector()
+	if c.opts.MutatingConfig != "" {
+		rules := BuildRules(c.mapper, listPolicies(c.opts.OverridePolicies), OverrideRulesField)
+		if err := c.syncMutating(ctx, rules, selector); err != nil {
+			return err
+		}
+	}
+
+	if c.opts.ValidatingConfig != "" {
+		rules := BuildRules(c.mapper, listPolicies(c.opts.ValidatePolicies), ValidateRulesField)
+		if err := c.syncValidating(ctx, rules, selector); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Controller) syncMutating(ctx context.Context, rules []admissionregistrationv1.RuleWithOperations, selector *metav1.LabelSelector) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		if err := c.client.Get(ctx, types.NamespacedName{Name: c.opts.MutatingConfig}, config); err != nil {
+			if apierrors.IsNotFound(err) {
+				klog.V(2).InfoS("mutating webhook configuration not found, skip.", "name", c.opts.MutatingConfig)
+				return nil
+			}
+			return err
+		}
+
+		managed := managedWebhooks(config)
+		if managed.Len() == 0 {
+			klog.InfoS("no webhook managed by kinitiras in mutating webhook configuration, skip.", "name", config.Name, "annotation", ManagedWebhooksAnnotation)
+			return nil
+		}
+
+		changed := false
+		for i := range config.Webhooks {
+			if !managed.Has(config.Webhooks[i].Name) {
+				continue
+			}
+			webhookRules, webhookSelector := rulesOfWebhook(config, config.Webhooks[i].Name, rules, selector)
+			if !reflect.DeepEqual(config.Webhooks[i].Rules, webhookRules) {
+				config.Webhooks[i].Rules = webhookRules
+				changed = true
+			}
+			if !reflect.DeepEqual(config.Webhooks[i].NamespaceSelector, webhookSelector) {
+				config.Webhooks[i].NamespaceSelector = webhookSelector
+				changed = true
+			}
+			if objectSelector := objectSelectorOfWebhook(config, config.Webhooks[i].Name, config.Webhooks[i].ObjectSelector); !reflect.DeepEqual(config.Webhooks[i].ObjectSelector, objectSelector) {
+				config.Webhooks[i].ObjectSelector = objectSelector
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+
+		klog.InfoS("update mutating webhook configuration.", "name", config.Name, "rules", len(rules))
+		return c.client.Update(ctx, config)
+	})
+}
+
+func (c *Controller) syncValidating(ctx context.Context, rules []admissionregistrationv1.RuleWithOperations, selector *metav1.LabelSelector) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		if err := c.client.Get(ctx, types.NamespacedName{Name: c.opts.ValidatingConfig}, config); err != nil {
+			if apierrors.IsNotFound(err) {
+				klog.V(2).InfoS("validating webhook configuration not found, skip.", "name", c.opts.ValidatingConfig)
+				return nil
+			}
+			return err
+		}
+
+		managed := managedWebhooks(config)
+		if managed.Len() == 0 {
+			klog.InfoS("no webhook managed by kinitiras in validating webhook configuration, skip.", "name", config.Name, "annotation", ManagedWebhooksAnnotation)
+			return nil
+		}
+
+		changed := false
+		for i := range config.Webhooks {
+			if !managed.Has(config.Webhooks[i].Name) {
+				continue
+			}
+			webhookRules, webhookSelector := rulesOfWebhook(config, config.Webhooks[i].Name, rules, selector)
+			if !reflect.DeepEqual(config.Webhooks[i].Rules, webhookRules) {
+				config.Webhooks[i].Rules = webhookRules
+				changed = true
+			}
+			if !reflect.DeepEqual(config.Webhooks[i].NamespaceSelector, webhookSelector) {
+				config.Webhooks[i].NamespaceSelector = webhookSelector
+				changed = true
+			}
+			if objectSelector := objectSelectorOfWebhook(config, config.Webhooks[i].Name, config.Webhooks[i].ObjectSelector); !reflect.DeepEqual(config.Webhooks[i].ObjectSelector, objectSelector) {
+				config.Webhooks[i].ObjectSelector = objectSelector
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+
+		klog.InfoS("update validating webhook configuration.", "name", config.Name, "rules", len(rules))
+		return c.client.Update(ctx, config)
+	})
+}
+
+// managedWebhooks returns the names of webhooks listed by ManagedWebhooksAnnotation of config.
+func managedWebhooks(config metav1.Object) sets.String {
+	managed := sets.NewString()
+	for _, name := range strings.Split(config.GetAnnotations()[ManagedWebhooksAnnotation], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			managed.Insert(name)
+		}
+	}
+
+	return managed
+}
+
+// rulesOfWebhook returns the rules and namespaceSelector of the managed webhook named name of config. rules are built
+// by BuildRules, which puts the rule of policies first.
+func rulesOfWebhook(config metav1.Object, name string, rules []admissionregistrationv1.RuleWithOperations,
+	selector *metav1.LabelSelector) ([]admissionregistrationv1.RuleWithOperations, *metav1.LabelSelector) {
+	policyWebhook := config.GetAnnotations()[PolicyWebhookAnnotation]
+	subresourceWebhook := config.GetAnnotations()[SubresourceWebhookAnnotation]
+	switch name {
+	case policyWebhook:
+		return rules[:1], &metav1.LabelSelector{}
+	case subresourceWebhook:
+		return subresourceRules(rules[1:], true), selector
+	}
+
+	if subresourceWebhook != "" {
+		rules = append(rules[:1:1], subresourceRules(rules[1:], false)...)
+	}
+	if policyWebhook == "" {
+		// policies in namespaces on the allowlist must reach the webhook
+		return rules, &metav1.LabelSelector{}
+	}
+	return rules[1:], selector
+}
+
+// objectSelectorOfWebhook returns the objectSelector of the managed webhook named name of config, current if it is
+// left as it is. The webhook of policies excludes labeled policies without managed rules, since they are routed to
+// the webhook of all resources then. Once the other managed webhooks lose the rule of policies, it selects all of them.
+func objectSelectorOfWebhook(config metav1.Object, name string, current *metav1.LabelSelector) *metav1.LabelSelector {
+	if name == config.GetAnnotations()[PolicyWebhookAnnotation] {
+		return &metav1.LabelSelector{}
+	}
+	return current
+}
+
+// subresourceRules returns rules with only subresources if subresources is true, or only main resources otherwise.
+// Rules left without resources are dropped.
+func subresourceRules(rules []admissionregistrationv1.RuleWithOperations, subresources bool) []admissionregistrationv1.RuleWithOperations {
+	ret := make([]admissionregistrationv1.RuleWithOperations, 0, len(rules))
+	for _, rule := range rules {
+		var resources []string
+		for _, resource := range rule.Resources {
+			if strings.Contains(resource, "/") == subresources {
+				resources = append(resources, resource)
+			}
+		}
+		if len(resources) == 0 {
+			continue
+		}
+		rule.Resources = resources
+		ret = append(ret, rule)
+	}
+	return ret
+}
+
+func listPolicies(stores []cache.Store) []*unstructured.Unstructured {
+	var result []*unstructured.Unstructured
+	for _, store := range stores {
+		for _, obj := range store.List() {
+			if policy, ok := obj.(*unstructured.Unstructured); ok {
+				result = append(result, policy)
+			}
+		}
+	}
+
+	return result
+}
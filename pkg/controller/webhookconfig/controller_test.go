@@ -0,0 +1,183 @@
+package webhookconfig
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestControllerSync(t *testing.T) {
+	handwritten := admissionregistrationv1.RuleWithOperations{
+		Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+		Rule:       admissionregistrationv1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"pods"}},
+	}
+	config := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "kinitiras-webhook",
+			Annotations: map[string]string{ManagedWebhooksAnnotation: "webhook.kinitiras.io"},
+		},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{Name: "webhook.kinitiras.io", Rules: []admissionregistrationv1.RuleWithOperations{handwritten}},
+			{Name: "handwritten.example.com", Rules: []admissionregistrationv1.RuleWithOperations{handwritten}},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	if err := admissionregistrationv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(config).Build()
+	controller := NewController(c, meta.NewDefaultRESTMapper([]schema.GroupVersion{}), Options{ValidatingConfig: config.Name})
+	if err := controller.sync(context.TODO()); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+
+	got := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: config.Name}, got); err != nil {
+		t.Fatal(err)
+	}
+	if want := []admissionregistrationv1.RuleWithOperations{policyRule}; !reflect.DeepEqual(got.Webhooks[0].Rules, want) {
+		t.Errorf("rules of managed webhook = %v, want %v", got.Webhooks[0].Rules, want)
+	}
+	if !reflect.DeepEqual(got.Webhooks[1], config.Webhooks[1]) {
+		t.Errorf("webhook not managed = %v, want %v", got.Webhooks[1], config.Webhooks[1])
+	}
+}
+
+func TestControllerSync_PolicyWebhook(t *testing.T) {
+	unlabeled := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "kinitiras.kcloudlabs.io/webhook", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"enabled"}},
+	}}
+	labeled := &metav1.LabelSelector{MatchLabels: map[string]string{"kinitiras.kcloudlabs.io/webhook": "enabled"}}
+	config := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "kinitiras-webhook",
+			Annotations: map[string]string{
+				ManagedWebhooksAnnotation: "webhook.kinitiras.io,policies.kinitiras.io",
+				PolicyWebhookAnnotation:   "policies.kinitiras.io",
+			},
+		},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{Name: "webhook.kinitiras.io", ObjectSelector: labeled},
+			{Name: "policies.kinitiras.io", ObjectSelector: unlabeled},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	if err := admissionregistrationv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(config).Build()
+	controller := NewController(c, meta.NewDefaultRESTMapper([]schema.GroupVersion{}), Options{ValidatingConfig: config.Name})
+	if err := controller.sync(context.TODO()); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+
+	got := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: config.Name}, got); err != nil {
+		t.Fatal(err)
+	}
+	// policies are only routed to the webhook of policies, which selects labeled policies as well
+	if len(got.Webhooks[0].Rules) != 0 || !reflect.DeepEqual(got.Webhooks[0].ObjectSelector, labeled) {
+		t.Errorf("webhook of resources = %+v, want no rules and the objectSelector kept", got.Webhooks[0])
+	}
+	if want := []admissionregistrationv1.RuleWithOperations{policyRule}; !reflect.DeepEqual(got.Webhooks[1].Rules, want) ||
+		!reflect.DeepEqual(got.Webhooks[1].ObjectSelector, &metav1.LabelSelector{}) {
+		t.Errorf("webhook of policies = %+v, want the rule of policies and an empty objectSelector", got.Webhooks[1])
+	}
+}
+
+func TestRulesOfWebhook(t *testing.T) {
+	podRule := admissionregistrationv1.RuleWithOperations{
+		Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+		Rule:       admissionregistrationv1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"pods"}},
+	}
+	execRule := admissionregistrationv1.RuleWithOperations{
+		Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Connect},
+		Rule:       admissionregistrationv1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"pods/exec"}},
+	}
+	mixedRule := admissionregistrationv1.RuleWithOperations{
+		Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Update},
+		Rule:       admissionregistrationv1.Rule{APIGroups: []string{"apps"}, APIVersions: []string{"v1"}, Resources: []string{"deployments", "deployments/scale"}},
+	}
+	mainRule, scaleRule := *mixedRule.DeepCopy(), *mixedRule.DeepCopy()
+	mainRule.Resources, scaleRule.Resources = []string{"deployments"}, []string{"deployments/scale"}
+	rules := []admissionregistrationv1.RuleWithOperations{policyRule, podRule}
+	allRules := []admissionregistrationv1.RuleWithOperations{policyRule, podRule, execRule, mixedRule}
+	subresourceAnnotations := map[string]string{
+		PolicyWebhookAnnotation:      "policies.kinitiras.io",
+		SubresourceWebhookAnnotation: "subresources.kinitiras.io",
+	}
+	selector := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "kubernetes.io/metadata.name", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"kube-system"}},
+	}}
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		webhook      string
+		rules        []admissionregistrationv1.RuleWithOperations
+		wantRules    []admissionregistrationv1.RuleWithOperations
+		wantSelector *metav1.LabelSelector
+	}{
+		{
+			name:         "no policy webhook",
+			webhook:      "webhook.kinitiras.io",
+			wantRules:    rules,
+			wantSelector: &metav1.LabelSelector{},
+		},
+		{
+			name:         "policy webhook",
+			annotations:  map[string]string{PolicyWebhookAnnotation: "policies.kinitiras.io"},
+			webhook:      "policies.kinitiras.io",
+			wantRules:    []admissionregistrationv1.RuleWithOperations{policyRule},
+			wantSelector: &metav1.LabelSelector{},
+		},
+		{
+			name:         "resource webhook",
+			annotations:  map[string]string{PolicyWebhookAnnotation: "policies.kinitiras.io"},
+			webhook:      "webhook.kinitiras.io",
+			wantRules:    []admissionregistrationv1.RuleWithOperations{podRule},
+			wantSelector: selector,
+		},
+		{
+			name:         "subresource webhook",
+			annotations:  subresourceAnnotations,
+			webhook:      "subresources.kinitiras.io",
+			rules:        allRules,
+			wantRules:    []admissionregistrationv1.RuleWithOperations{execRule, scaleRule},
+			wantSelector: selector,
+		},
+		{
+			name:         "resource webhook without subresources",
+			annotations:  subresourceAnnotations,
+			webhook:      "webhook.kinitiras.io",
+			rules:        allRules,
+			wantRules:    []admissionregistrationv1.RuleWithOperations{podRule, mainRule},
+			wantSelector: selector,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &metav1.ObjectMeta{Annotations: tt.annotations}
+			if tt.rules == nil {
+				tt.rules = rules
+			}
+			gotRules, gotSelector := rulesOfWebhook(config, tt.webhook, tt.rules, selector)
+			if !reflect.DeepEqual(gotRules, tt.wantRules) {
+				t.Errorf("rulesOfWebhook() rules = %v, want %v", gotRules, tt.wantRules)
+			}
+			if !reflect.DeepEqual(gotSelector, tt.wantSelector) {
+				t.Errorf("rulesOfWebhook() selector = %v, want %v", gotSelector, tt.wantSelector)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package webhookconfig
+
+import (
+	"sort"
+	"strings"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/policyfilter"
+)
+
+const (
+	policyGroup   = "policy.kcloudlabs.io"
+	policyVersion = "v1alpha1"
+
+	// OverrideRulesField is the field of (Cluster)OverridePolicy holding rules with operations.
+	OverrideRulesField = "overrideRules"
+	// ValidateRulesField is the field of ClusterValidatePolicy holding rules with operations.
+	ValidateRulesField = "validateRules"
+)
+
+var (
+	allOperations = []admissionregistrationv1.OperationType{
+		admissionregistrationv1.Create,
+		admissionregistrationv1.Update,
+		admissionregistrationv1.Delete,
+	}
+	// allSubresourceOperations are the operations of subresources, e.g. pods/exec and pods/attach are only connected.
+	allSubresourceOperations = append(allOperations[:len(allOperations):len(allOperations)], admissionregistrationv1.Connect)
+
+	// policyRule routes policies themselves to the webhook, so policy interrupters can check them, and delete hook
+	// policies are validated on deletion as well.
+	policyRule = admissionregistrationv1.RuleWithOperations{
+		Operations: allOperations,
+		Rule: admissionregistrationv1.Rule{
+			APIGroups:   []string{policyGroup},
+			APIVersions: []string{policyVersion},
+			Resources:   []string{"clusteroverridepolicies", "clustervalidatepolicies", "overridepolicies"},
+			Scope:       scopePtr(admissionregistrationv1.AllScopes),
+		},
+	}
+)
+
+// BuildRules returns the webhook rules covering every resource and operation selected by policies.
+// rulesField is the spec field holding rules with targetOperations, OverrideRulesField or ValidateRulesField.
+// Selectors whose kind is unknown to mapper are skipped. The rule of policies themselves is always the first one.
+func BuildRules(mapper meta.RESTMapper, policies []*unstructured.Unstructured, rulesField string) []admissionregistrationv1.RuleWithOperations {
+	// operations of each resource
+	operations := make(map[schema.GroupVersionResource]sets.String)
+	for _, policy := range policies {
+		ops := policyOperations(policy, rulesField, allOperations)
+		if len(ops) == 0 {
+			continue
+		}
+		subresourceOps := policyOperations(policy, rulesField, allSubresourceOperations)
+		for _, gvr := range selectedResources(mapper, policy) {
+			if operations[gvr] == nil {
+				operations[gvr] = sets.NewString()
+			}
+			if strings.Contains(gvr.Resource, "/") {
+				operations[gvr].Insert(subresourceOps...)
+			} else {
+				operations[gvr].Insert(ops...)
+			}
+		}
+	}
+
+	// merge resources with same group, version and operations into one rule
+	type ruleKey struct {
+		group, version, operations string
+	}
+	resources := make(map[ruleKey][]string)
+	for gvr, ops := range operations {
+		key := ruleKey{group: gvr.Group, version: gvr.Version, operations: strings.Join(ops.List(), ",")}
+		resources[key] = append(resources[key], gvr.Resource)
+	}
+
+	rules := make([]admissionregistrationv1.RuleWithOperations, 0, len(resources)+1)
+	rules = append(rules, policyRule)
+	for key, res := range resources {
+		sort.Strings(res)
+		ops := make([]admissionregistrationv1.OperationType, 0, len(allSubresourceOperations))
+		for _, op := range strings.Split(key.operations, ",") {
+			ops = append(ops, admissionregistrationv1.OperationType(op))
+		}
+		rules = append(rules, admissionregistrationv1.RuleWithOperations{
+			Operations: ops,
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{key.group},
+				APIVersions: []string{key.version},
+				Resources:   res,
+				Scope:       scopePtr(admissionregistrationv1.AllScopes),
+			},
+		})
+	}
+
+	sort.SliceStable(rules[1:], func(i, j int) bool {
+		a, b := rules[i+1], rules[j+1]
+		if a.APIGroups[0] != b.APIGroups[0] {
+			return a.APIGroups[0] < b.APIGroups[0]
+		}
+		if a.APIVersions[0] != b.APIVersions[0] {
+			return a.APIVersions[0] < b.APIVersions[0]
+		}
+		return a.Resources[0] < b.Resources[0]
+	})
+
+	return rules
+}
+
+// selectedResources returns the resources in spec.resourceSelectors of policy and the subresources in
+// its subresources annotation.
+func selectedResources(mapper meta.RESTMapper, policy *unstructured.Unstructured) []schema.GroupVersionResource {
+	subresources, err := policyfilter.SubresourcesFromAnnotations(policy.GetAnnotations())
+	if err != nil {
+		klog.V(2).InfoS("skip invalid subresources of policy.", "policy", klog.KObj(policy), "err", err)
+	}
+
+	var result []schema.GroupVersionResource
+	allSubresources := false
+	for _, subresource := range subresources {
+		if subresource.Resource == "" {
+			allSubresources = true
+			continue
+		}
+
+		gvrs, err := mapper.ResourcesFor(subresource.GroupResource.WithVersion(""))
+		if err != nil {
+			klog.V(2).InfoS("skip unknown subresource of policy.", "policy", klog.KObj(policy), "subresource", subresource, "err", err)
+			continue
+		}
+		for _, gvr := range gvrs {
+			gvr.Resource += "/" + subresource.Subresource
+			result = append(result, gvr)
+		}
+	}
+
+	for _, gvk := range selectedKinds(policy) {
+		mappings, err := mapper.RESTMappings(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			klog.V(2).InfoS("skip unknown resource selected by policy.", "policy", klog.KObj(policy), "gvk", gvk, "err", err)
+			continue
+		}
+		for _, mapping := range mappings {
+			result = append(result, mapping.Resource)
+			if allSubresources {
+				gvr := mapping.Resource
+				gvr.Resource += "/" + policyfilter.AllSubresources
+				result = append(result, gvr)
+			}
+		}
+	}
+
+	return result
+}
+
+// selectedKinds returns the kinds in spec.resourceSelectors of policy.
+func selectedKinds(policy *unstructured.Unstructured) []schema.GroupVersionKind {
+	selectors, _, _ := unstructured.NestedSlice(policy.Object, "spec", "resourceSelectors")
+
+	var result []schema.GroupVersionKind
+	for _, item := range selectors {
+		selector, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		apiVersion, _ := selector["apiVersion"].(string)
+		kind, _ := selector["kind"].(string)
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil || kind == "" {
+			continue
+		}
+		result = append(result, gv.WithKind(kind))
+	}
+
+	return result
+}
+
+// policyOperations returns the union of targetOperations of all rules in policy.
+// A rule without targetOperations applies to all operations in defaults.
+func policyOperations(policy *unstructured.Unstructured, rulesField string, defaults []admissionregistrationv1.OperationType) []string {
+	rules, _, _ := unstructured.NestedSlice(policy.Object, "spec", rulesField)
+
+	ops := sets.NewString()
+	for _, item := range rules {
+		rule, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		targets, _, _ := unstructured.NestedStringSlice(rule, "targetOperations")
+		if len(targets) == 0 {
+			for _, op := range defaults {
+				ops.Insert(string(op))
+			}
+			continue
+		}
+		ops.Insert(targets...)
+	}
+
+	return ops.List()
+}
+
+func scopePtr(scope admissionregistrationv1.ScopeType) *admissionregistrationv1.ScopeType {
+	return &scope
+}
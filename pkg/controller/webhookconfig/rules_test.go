@@ -0,0 +1,142 @@
+package webhookconfig
+
+import (
+	"reflect"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/policyfilter"
+)
+
+func newMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Service"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	return mapper
+}
+
+func newPolicy(rulesField string, selectors []interface{}, ops ...[]interface{}) *unstructured.Unstructured {
+	rules := make([]interface{}, 0, len(ops))
+	for _, op := range ops {
+		rule := map[string]interface{}{}
+		if op != nil {
+			rule["targetOperations"] = op
+		}
+		rules = append(rules, rule)
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"resourceSelectors": selectors,
+			rulesField:          rules,
+		},
+	}}
+}
+
+func withSubresources(policy *unstructured.Unstructured, subresources string) *unstructured.Unstructured {
+	policy.SetAnnotations(map[string]string{policyfilter.SubresourcesAnnotation: subresources})
+	return policy
+}
+
+func selector(apiVersion, kind string) map[string]interface{} {
+	return map[string]interface{}{"apiVersion": apiVersion, "kind": kind}
+}
+
+func rule(group string, resources []string, ops ...admissionregistrationv1.OperationType) admissionregistrationv1.RuleWithOperations {
+	return admissionregistrationv1.RuleWithOperations{
+		Operations: ops,
+		Rule: admissionregistrationv1.Rule{
+			APIGroups:   []string{group},
+			APIVersions: []string{"v1"},
+			Resources:   resources,
+			Scope:       scopePtr(admissionregistrationv1.AllScopes),
+		},
+	}
+}
+
+func TestBuildRules(t *testing.T) {
+	create, update, del := admissionregistrationv1.Create, admissionregistrationv1.Update, admissionregistrationv1.Delete
+	tests := []struct {
+		name     string
+		policies []*unstructured.Unstructured
+		field    string
+		want     []admissionregistrationv1.RuleWithOperations
+	}{
+		{
+			name: "no policy",
+			want: []admissionregistrationv1.RuleWithOperations{policyRule},
+		},
+		{
+			name:  "merge resources with same operations",
+			field: OverrideRulesField,
+			policies: []*unstructured.Unstructured{
+				newPolicy(OverrideRulesField, []interface{}{selector("v1", "Pod")}, []interface{}{"CREATE"}),
+				newPolicy(OverrideRulesField, []interface{}{selector("v1", "Service"), selector("apps/v1", "Deployment")}, []interface{}{"CREATE"}),
+			},
+			want: []admissionregistrationv1.RuleWithOperations{
+				policyRule,
+				rule("", []string{"pods", "services"}, create),
+				rule("apps", []string{"deployments"}, create),
+			},
+		},
+		{
+			name:  "union of operations",
+			field: ValidateRulesField,
+			policies: []*unstructured.Unstructured{
+				newPolicy(ValidateRulesField, []interface{}{selector("v1", "Pod")}, []interface{}{"CREATE"}, []interface{}{"UPDATE"}),
+				newPolicy(ValidateRulesField, []interface{}{selector("v1", "Namespace")}, nil),
+			},
+			want: []admissionregistrationv1.RuleWithOperations{
+				policyRule,
+				rule("", []string{"namespaces"}, create, del, update),
+				rule("", []string{"pods"}, create, update),
+			},
+		},
+		{
+			name:  "unknown kind",
+			field: OverrideRulesField,
+			policies: []*unstructured.Unstructured{
+				newPolicy(OverrideRulesField, []interface{}{selector("foo.io/v1", "Foo")}, []interface{}{"CREATE"}),
+			},
+			want: []admissionregistrationv1.RuleWithOperations{policyRule},
+		},
+		{
+			name:  "subresources",
+			field: ValidateRulesField,
+			policies: []*unstructured.Unstructured{
+				withSubresources(newPolicy(ValidateRulesField, []interface{}{selector("v1", "PodExecOptions")}, []interface{}{"CONNECT"}), "pods/exec"),
+				withSubresources(newPolicy(ValidateRulesField, []interface{}{selector("apps/v1", "Deployment")}, []interface{}{"UPDATE"}), "*"),
+			},
+			want: []admissionregistrationv1.RuleWithOperations{
+				policyRule,
+				rule("", []string{"pods/exec"}, admissionregistrationv1.Connect),
+				rule("apps", []string{"deployments", "deployments/*"}, update),
+			},
+		},
+		{
+			name:  "subresources without target operations",
+			field: OverrideRulesField,
+			policies: []*unstructured.Unstructured{
+				withSubresources(newPolicy(OverrideRulesField, []interface{}{selector("v1", "Pod")}, nil), "pods/exec"),
+			},
+			want: []admissionregistrationv1.RuleWithOperations{
+				policyRule,
+				rule("", []string{"pods"}, create, del, update),
+				rule("", []string{"pods/exec"}, admissionregistrationv1.Connect, create, del, update),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BuildRules(newMapper(), tt.policies, tt.field); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildRules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
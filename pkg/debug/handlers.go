@@ -0,0 +1,290 @@
+package debug
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/k-cloud-labs/pkg/utils/dynamiclister"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/policyorder"
+	pkgwebhook "github.com/k-cloud-labs/kinitiras/pkg/webhook"
+)
+
+// Paths of the debug handlers.
+const (
+	PoliciesPath  = "/debug/kinitiras/policies"
+	CuePath       = "/debug/kinitiras/cue"
+	ResourcesPath = "/debug/kinitiras/resources"
+	EvaluatePath  = "/debug/kinitiras/evaluate"
+)
+
+// maxObjectSize limits the size of objects uploaded to EvaluatePath.
+const maxObjectSize = 3 * 1024 * 1024
+
+// Options contains the sources of the debug handlers.
+type Options struct {
+	// PolicyManagers provides the live policy set.
+	PolicyManagers *pkgwebhook.PolicyManagers
+	// DynamicLister is the lister of cached resources.
+	DynamicLister dynamiclister.DynamicResourceLister
+	// Resources returns the kinds of resources registered to DynamicLister.
+	Resources func() []schema.GroupVersionKind
+}
+
+// Handlers returns the read-only policy debug handlers by path.
+func Handlers(opts Options) map[string]http.Handler {
+	h := &handlers{opts: opts}
+	return map[string]http.Handler{
+		PoliciesPath:  http.HandlerFunc(h.policies),
+		CuePath:       http.HandlerFunc(h.cue),
+		ResourcesPath: http.HandlerFunc(h.resources),
+		EvaluatePath:  http.HandlerFunc(h.evaluate),
+	}
+}
+
+type handlers struct {
+	opts Options
+}
+
+// Policy is a loaded policy.
+type Policy struct {
+	Kind     string      `json:"kind"`
+	Priority int32       `json:"priority"`
+	Policy   interface{} `json:"policy"`
+}
+
+// policies lists loaded policies in the form parsed from the cache, including their generations.
+func (h *handlers) policies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.listPolicies()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, policies)
+}
+
+func (h *handlers) listPolicies() ([]Policy, error) {
+	m := h.opts.PolicyManagers
+	if m.OverridePolicyLister == nil || m.ClusterOverridePolicyLister == nil || m.ClusterValidatePolicyLister == nil {
+		return nil, errors.New("policies are not loaded yet")
+	}
+
+	var policies []Policy
+	cops, err := m.ClusterOverridePolicyLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, cop := range cops {
+		policies = append(policies, Policy{Kind: "ClusterOverridePolicy", Priority: priority(cop), Policy: cop})
+	}
+	ops, err := m.OverridePolicyLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		policies = append(policies, Policy{Kind: "OverridePolicy", Priority: priority(op), Policy: op})
+	}
+	cvps, err := m.ClusterValidatePolicyLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, cvp := range cvps {
+		policies = append(policies, Policy{Kind: "ClusterValidatePolicy", Policy: cvp})
+	}
+
+	return policies, nil
+}
+
+// cue shows the cue of a rule, the rendered cue of template rules, selected by the query parameters
+// kind, namespace, name and rule, the index of the rule.
+func (h *handlers) cue(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	index, err := strconv.Atoi(query.Get("rule"))
+	if err != nil {
+		http.Error(w, "invalid rule index: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	policies, err := h.listPolicies()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, policy := range policies {
+		obj, ok := policy.Policy.(metav1.Object)
+		if !ok || policy.Kind != query.Get("kind") || obj.GetNamespace() != query.Get("namespace") || obj.GetName() != query.Get("name") {
+			continue
+		}
+
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(policy.Policy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cue, err := ruleCue(content, index)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, cue)
+		return
+	}
+
+	http.Error(w, "policy not found", http.StatusNotFound)
+}
+
+// ruleCue returns the cue of rule index of a policy, the rendered cue is preferred to the cue written by user.
+func ruleCue(policy map[string]interface{}, index int) (string, error) {
+	var rules []interface{}
+	for _, field := range []string{"overrideRules", "validateRules"} {
+		if items, ok, _ := unstructured.NestedSlice(policy, "spec", field); ok {
+			rules = items
+		}
+	}
+	if index < 0 || index >= len(rules) {
+		return "", fmt.Errorf("rule %d not found", index)
+	}
+
+	for _, key := range []string{"renderedCue", "cue"} {
+		if cue := findString(rules[index], key); cue != "" {
+			return cue, nil
+		}
+	}
+	return "", fmt.Errorf("rule %d has no cue", index)
+}
+
+// findString returns the first non-empty string of key in v.
+func findString(v interface{}, key string) string {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if s, ok := value[key].(string); ok && s != "" {
+			return s
+		}
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if s := findString(value[k], key); s != "" {
+				return s
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if s := findString(item, key); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// Resource is a kind of resources registered to the dynamic lister.
+type Resource struct {
+	schema.GroupVersionKind `json:",inline"`
+	Count                   int    `json:"count"`
+	Error                   string `json:"error,omitempty"`
+}
+
+// resources lists the kinds of resources registered to the dynamic lister with object counts.
+func (h *handlers) resources(w http.ResponseWriter, r *http.Request) {
+	var resources []Resource
+	for _, gvk := range h.opts.Resources() {
+		resource := Resource{GroupVersionKind: gvk}
+		objs, err := h.opts.DynamicLister.ListResource(gvk, "", labels.Everything())
+		if err != nil {
+			resource.Error = err.Error()
+		}
+		resource.Count = len(objs)
+		resources = append(resources, resource)
+	}
+
+	writeJSON(w, resources)
+}
+
+// evaluate applies the live policy set to the object in the body of a POST request, in yaml or json.
+// Query parameters operation, namespace, username and groups describe the admission request.
+func (h *handlers) evaluate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	obj := &unstructured.Unstructured{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(io.LimitReader(r.Body, maxObjectSize), 4096)
+	if err := decoder.Decode(&obj.Object); err != nil {
+		http.Error(w, "failed to decode object: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	operation := admissionv1.Operation(query.Get("operation"))
+	if operation == "" {
+		operation = admissionv1.Create
+	}
+	if operation != admissionv1.Create {
+		http.Error(w, "only CREATE is supported, since there is no old object", http.StatusBadRequest)
+		return
+	}
+	if namespace := query.Get("namespace"); namespace != "" {
+		obj.SetNamespace(namespace)
+	}
+	var groups []string
+	if query.Get("groups") != "" {
+		groups = strings.Split(query.Get("groups"), ",")
+	}
+
+	gvk := obj.GroupVersionKind()
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+		Operation: operation,
+		UserInfo:  authenticationv1.UserInfo{Username: query.Get("username"), Groups: groups},
+	}}
+	evaluation, err := h.opts.PolicyManagers.Evaluate(r.Context(), req, obj, nil, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, evaluation)
+}
+
+func priority(policy metav1.Object) int32 {
+	p, err := policyorder.PriorityFromAnnotations(policy.GetAnnotations())
+	if err != nil {
+		klog.ErrorS(err, "use default priority.", "policy", klog.KObj(policy))
+	}
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		klog.ErrorS(err, "failed to write debug response.")
+	}
+}
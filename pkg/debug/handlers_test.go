@@ -0,0 +1,43 @@
+package debug
+
+import (
+	"testing"
+)
+
+func TestRuleCue(t *testing.T) {
+	policy := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"overrideRules": []interface{}{
+				map[string]interface{}{"overriders": map[string]interface{}{"cue": "object: _"}},
+				map[string]interface{}{"overriders": map[string]interface{}{
+					"template":    map[string]interface{}{"type": "annotations"},
+					"renderedCue": "rendered: _",
+				}},
+				map[string]interface{}{"overriders": map[string]interface{}{"plaintext": []interface{}{}}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		index   int
+		want    string
+		wantErr bool
+	}{
+		{name: "1", index: 0, want: "object: _"},
+		{name: "2", index: 1, want: "rendered: _"},
+		{name: "no cue", index: 2, wantErr: true},
+		{name: "not found", index: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ruleCue(policy, tt.index)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ruleCue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ruleCue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
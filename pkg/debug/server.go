@@ -0,0 +1,49 @@
+package debug
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Server serves debug handlers on a separate bind address.
+type Server struct {
+	// BindAddress is the address the server listens on, e.g. 127.0.0.1:8081.
+	BindAddress string
+	// Handlers are the handlers by path.
+	Handlers map[string]http.Handler
+}
+
+var _ manager.Runnable = &Server{}
+
+// Start implements manager.Runnable, it blocks until ctx is done.
+func (s *Server) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	for path, handler := range s.Handlers {
+		mux.Handle(path, handler)
+	}
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	listener, err := net.Listen("tcp", s.BindAddress)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := srv.Shutdown(context.Background()); err != nil {
+			klog.ErrorS(err, "failed to shutdown debug server.")
+		}
+	}()
+
+	klog.InfoS("starting debug server.", "address", listener.Addr().String())
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
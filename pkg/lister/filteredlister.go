@@ -0,0 +1,105 @@
+package lister
+
+import (
+	policyv1alpha1 "github.com/k-cloud-labs/pkg/apis/policy/v1alpha1"
+	"github.com/k-cloud-labs/pkg/client/listers/policy/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// PolicyFilter returns true if the policy should be listed.
+type PolicyFilter func(policy metav1.Object) bool
+
+// filteredClusterOverridePolicyLister lists ClusterOverridePolicies which pass the filter.
+type filteredClusterOverridePolicyLister struct {
+	v1alpha1.ClusterOverridePolicyLister
+	filter PolicyFilter
+}
+
+// NewFilteredClusterOverridePolicyLister returns a ClusterOverridePolicyLister whose List only returns
+// policies passing filter.
+func NewFilteredClusterOverridePolicyLister(lister v1alpha1.ClusterOverridePolicyLister, filter PolicyFilter) v1alpha1.ClusterOverridePolicyLister {
+	return &filteredClusterOverridePolicyLister{ClusterOverridePolicyLister: lister, filter: filter}
+}
+
+// List lists ClusterOverridePolicies passing the filter.
+func (s *filteredClusterOverridePolicyLister) List(selector labels.Selector) (ret []*policyv1alpha1.ClusterOverridePolicy, err error) {
+	policies, err := s.ClusterOverridePolicyLister.List(selector)
+	for _, policy := range policies {
+		if s.filter(policy) {
+			ret = append(ret, policy)
+		}
+	}
+	return ret, err
+}
+
+// filteredOverridePolicyLister lists OverridePolicies which pass the filter.
+type filteredOverridePolicyLister struct {
+	v1alpha1.OverridePolicyLister
+	filter PolicyFilter
+}
+
+// NewFilteredOverridePolicyLister returns an OverridePolicyLister whose List only returns
+// policies passing filter.
+func NewFilteredOverridePolicyLister(lister v1alpha1.OverridePolicyLister, filter PolicyFilter) v1alpha1.OverridePolicyLister {
+	return &filteredOverridePolicyLister{OverridePolicyLister: lister, filter: filter}
+}
+
+// List lists OverridePolicies passing the filter.
+func (s *filteredOverridePolicyLister) List(selector labels.Selector) (ret []*policyv1alpha1.OverridePolicy, err error) {
+	policies, err := s.OverridePolicyLister.List(selector)
+	for _, policy := range policies {
+		if s.filter(policy) {
+			ret = append(ret, policy)
+		}
+	}
+	return ret, err
+}
+
+// OverridePolicies returns an object that can list and get OverridePolicies passing the filter.
+func (s *filteredOverridePolicyLister) OverridePolicies(namespace string) v1alpha1.OverridePolicyNamespaceLister {
+	return filteredOverridePolicyNamespaceLister{
+		OverridePolicyNamespaceLister: s.OverridePolicyLister.OverridePolicies(namespace),
+		filter:                        s.filter,
+	}
+}
+
+// filteredOverridePolicyNamespaceLister lists OverridePolicies of a namespace which pass the filter.
+type filteredOverridePolicyNamespaceLister struct {
+	v1alpha1.OverridePolicyNamespaceLister
+	filter PolicyFilter
+}
+
+// List lists OverridePolicies of the namespace passing the filter.
+func (s filteredOverridePolicyNamespaceLister) List(selector labels.Selector) (ret []*policyv1alpha1.OverridePolicy, err error) {
+	policies, err := s.OverridePolicyNamespaceLister.List(selector)
+	for _, policy := range policies {
+		if s.filter(policy) {
+			ret = append(ret, policy)
+		}
+	}
+	return ret, err
+}
+
+// filteredClusterValidatePolicyLister lists ClusterValidatePolicies which pass the filter.
+type filteredClusterValidatePolicyLister struct {
+	v1alpha1.ClusterValidatePolicyLister
+	filter PolicyFilter
+}
+
+// NewFilteredClusterValidatePolicyLister returns a ClusterValidatePolicyLister whose List only returns
+// policies passing filter.
+func NewFilteredClusterValidatePolicyLister(lister v1alpha1.ClusterValidatePolicyLister, filter PolicyFilter) v1alpha1.ClusterValidatePolicyLister {
+	return &filteredClusterValidatePolicyLister{ClusterValidatePolicyLister: lister, filter: filter}
+}
+
+// List lists ClusterValidatePolicies passing the filter.
+func (s *filteredClusterValidatePolicyLister) List(selector labels.Selector) (ret []*policyv1alpha1.ClusterValidatePolicy, err error) {
+	policies, err := s.ClusterValidatePolicyLister.List(selector)
+	for _, policy := range policies {
+		if s.filter(policy) {
+			ret = append(ret, policy)
+		}
+	}
+	return ret, err
+}
@@ -0,0 +1,104 @@
+package lister
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+
+	policyv1alpha1 "github.com/k-cloud-labs/pkg/apis/policy/v1alpha1"
+	"github.com/k-cloud-labs/pkg/client/listers/policy/v1alpha1"
+)
+
+// sliceClusterOverridePolicyLister lists ClusterOverridePolicies which were listed before.
+type sliceClusterOverridePolicyLister []*policyv1alpha1.ClusterOverridePolicy
+
+// NewSliceClusterOverridePolicyLister returns a ClusterOverridePolicyLister which only lists policies.
+func NewSliceClusterOverridePolicyLister(policies ...*policyv1alpha1.ClusterOverridePolicy) v1alpha1.ClusterOverridePolicyLister {
+	return sliceClusterOverridePolicyLister(policies)
+}
+
+// List lists ClusterOverridePolicies matching selector.
+func (s sliceClusterOverridePolicyLister) List(selector labels.Selector) (ret []*policyv1alpha1.ClusterOverridePolicy, err error) {
+	for _, policy := range s {
+		if selector.Matches(labels.Set(policy.Labels)) {
+			ret = append(ret, policy)
+		}
+	}
+	return ret, nil
+}
+
+// Get retrieves the ClusterOverridePolicy for a given name.
+func (s sliceClusterOverridePolicyLister) Get(name string) (*policyv1alpha1.ClusterOverridePolicy, error) {
+	for _, policy := range s {
+		if policy.Name == name {
+			return policy, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(policyv1alpha1.Resource("clusteroverridepolicy"), name)
+}
+
+// sliceOverridePolicyLister lists OverridePolicies which were listed before.
+type sliceOverridePolicyLister []*policyv1alpha1.OverridePolicy
+
+// NewSliceOverridePolicyLister returns an OverridePolicyLister which only lists policies.
+func NewSliceOverridePolicyLister(policies ...*policyv1alpha1.OverridePolicy) v1alpha1.OverridePolicyLister {
+	return sliceOverridePolicyLister(policies)
+}
+
+// List lists OverridePolicies matching selector.
+func (s sliceOverridePolicyLister) List(selector labels.Selector) (ret []*policyv1alpha1.OverridePolicy, err error) {
+	for _, policy := range s {
+		if selector.Matches(labels.Set(policy.Labels)) {
+			ret = append(ret, policy)
+		}
+	}
+	return ret, nil
+}
+
+// OverridePolicies returns an object that can list and get OverridePolicies of namespace.
+func (s sliceOverridePolicyLister) OverridePolicies(namespace string) v1alpha1.OverridePolicyNamespaceLister {
+	var policies sliceOverridePolicyLister
+	for _, policy := range s {
+		if policy.Namespace == namespace {
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
+// Get retrieves the OverridePolicy for a given name, it is only called on the lister of a namespace.
+func (s sliceOverridePolicyLister) Get(name string) (*policyv1alpha1.OverridePolicy, error) {
+	for _, policy := range s {
+		if policy.Name == name {
+			return policy, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(policyv1alpha1.Resource("overridepolicy"), name)
+}
+
+// sliceClusterValidatePolicyLister lists ClusterValidatePolicies which were listed before.
+type sliceClusterValidatePolicyLister []*policyv1alpha1.ClusterValidatePolicy
+
+// NewSliceClusterValidatePolicyLister returns a ClusterValidatePolicyLister which only lists policies.
+func NewSliceClusterValidatePolicyLister(policies ...*policyv1alpha1.ClusterValidatePolicy) v1alpha1.ClusterValidatePolicyLister {
+	return sliceClusterValidatePolicyLister(policies)
+}
+
+// List lists ClusterValidatePolicies matching selector.
+func (s sliceClusterValidatePolicyLister) List(selector labels.Selector) (ret []*policyv1alpha1.ClusterValidatePolicy, err error) {
+	for _, policy := range s {
+		if selector.Matches(labels.Set(policy.Labels)) {
+			ret = append(ret, policy)
+		}
+	}
+	return ret, nil
+}
+
+// Get retrieves the ClusterValidatePolicy for a given name.
+func (s sliceClusterValidatePolicyLister) Get(name string) (*policyv1alpha1.ClusterValidatePolicy, error) {
+	for _, policy := range s {
+		if policy.Name == name {
+			return policy, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(policyv1alpha1.Resource("clustervalidatepolicy"), name)
+}
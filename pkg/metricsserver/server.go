@@ -0,0 +1,145 @@
+package metricsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/auth"
+	"github.com/k-cloud-labs/kinitiras/pkg/util/tlsconfig"
+)
+
+const (
+	metricsPath = "/metrics"
+	certName    = "tls.crt"
+	keyName     = "tls.key"
+)
+
+// Options contains settings of the secure metrics server.
+type Options struct {
+	// BindAddress is the address the server listens on, e.g. :8443.
+	BindAddress string
+	// CertDir is the directory containing tls.crt and tls.key, which are reloaded when they change.
+	// The server waits for them to be written, e.g. by the cert rotator.
+	CertDir string
+	// TLS contains the minimum version, cipher suites and curves of the server.
+	TLS tlsconfig.Config
+	// Filter authenticates and authorizes requests to /metrics, nil means no protection.
+	Filter auth.Filter
+}
+
+// Server serves the metrics of the controller-runtime registry over HTTPS, replacing the plain HTTP
+// metrics server of the manager.
+type Server struct {
+	opts Options
+
+	mu      sync.Mutex
+	started bool
+	extra   map[string]http.Handler
+}
+
+var _ manager.Runnable = &Server{}
+
+// New builds a Server. Start it by manager.
+func New(opts Options) *Server {
+	return &Server{opts: opts, extra: map[string]http.Handler{}}
+}
+
+// AddExtraHandler adds a handler besides /metrics, the same as AddMetricsExtraHandler of the manager.
+// The handler is served as it is, without the Filter.
+func (s *Server) AddExtraHandler(path string, handler http.Handler) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return errors.New("unable to add extra handlers after the metrics server started")
+	}
+	if path == metricsPath {
+		return fmt.Errorf("overriding builtin %s endpoint is not allowed", metricsPath)
+	}
+	if _, ok := s.extra[path]; ok {
+		return fmt.Errorf("can't register extra handler by duplicate path %q", path)
+	}
+	s.extra[path] = handler
+	return nil
+}
+
+// Start implements manager.Runnable, it blocks until ctx is done.
+func (s *Server) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	var handler http.Handler = promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{ErrorHandling: promhttp.HTTPErrorOnError})
+	if s.opts.Filter != nil {
+		handler = s.opts.Filter(handler)
+	}
+	mux.Handle(metricsPath, handler)
+
+	s.mu.Lock()
+	s.started = true
+	for path, extra := range s.extra {
+		mux.Handle(path, extra)
+	}
+	s.mu.Unlock()
+
+	certPath, keyPath := filepath.Join(s.opts.CertDir, certName), filepath.Join(s.opts.CertDir, keyName)
+	if err := waitForFiles(ctx, certPath, keyPath); err != nil {
+		return err
+	}
+	watcher, err := certwatcher.New(certPath, keyPath)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := watcher.Start(ctx); err != nil {
+			klog.ErrorS(err, "certificate watcher of metrics server exits.")
+		}
+	}()
+
+	cfg := &tls.Config{GetCertificate: watcher.GetCertificate}
+	s.opts.TLS.Apply(cfg)
+	listener, err := tls.Listen("tcp", s.opts.BindAddress, cfg)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		if err := srv.Shutdown(context.Background()); err != nil {
+			klog.ErrorS(err, "failed to shutdown metrics server.")
+		}
+	}()
+
+	klog.InfoS("starting secure metrics server.", "address", listener.Addr().String())
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// waitForFiles waits until all files exist or ctx is done.
+func waitForFiles(ctx context.Context, files ...string) error {
+	return wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		for _, file := range files {
+			if _, err := os.Stat(file); err != nil {
+				if os.IsNotExist(err) {
+					return false, nil
+				}
+				return false, err
+			}
+		}
+		return true, nil
+	}, ctx.Done())
+}
@@ -0,0 +1,99 @@
+package policyfilter
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/userinfo"
+)
+
+// NamespaceSelectorAnnotation is the annotation of policies holding a json encoded metav1.LabelSelector.
+// A policy with it only applies to namespaced resources in namespaces whose labels match the selector.
+const NamespaceSelectorAnnotation = "kinitiras.kcloudlabs.io/namespace-selector"
+
+// NamespaceLabelsFunc returns labels of the namespace and whether it exists.
+type NamespaceLabelsFunc func(namespace string) (map[string]string, bool)
+
+// NamespaceSelectorFromAnnotations returns the namespace selector stored in annotations, or nil if there is none.
+func NamespaceSelectorFromAnnotations(annotations map[string]string) (labels.Selector, error) {
+	val, ok := annotations[NamespaceSelectorAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	labelSelector := &metav1.LabelSelector{}
+	if err := json.Unmarshal([]byte(val), labelSelector); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %w", NamespaceSelectorAnnotation, err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %w", NamespaceSelectorAnnotation, err)
+	}
+
+	return selector, nil
+}
+
+// ValidateAnnotations returns an error if selector annotations of a policy are invalid.
+func ValidateAnnotations(annotations map[string]string) error {
+	if _, err := userinfo.SelectorFromAnnotations(annotations); err != nil {
+		return err
+	}
+	if _, err := NamespaceSelectorFromAnnotations(annotations); err != nil {
+		return err
+	}
+	_, err := SubresourcesFromAnnotations(annotations)
+	return err
+}
+
+// New returns a filter of policies which select req by the user who sent it, by labels of its namespace
+// and by its subresource. Policies with invalid selector annotations are filtered out.
+func New(req admission.Request, namespaceLabels NamespaceLabelsFunc) func(policy metav1.Object) bool {
+	resource := schema.GroupResource{Group: req.Resource.Group, Resource: req.Resource.Resource}
+	return func(policy metav1.Object) bool {
+		subresources, err := SubresourcesFromAnnotations(policy.GetAnnotations())
+		if err != nil {
+			klog.ErrorS(err, "skip policy with invalid subresources.", "policy", klog.KObj(policy))
+			return false
+		}
+		if !matchSubresource(subresources, resource, req.SubResource) {
+			return false
+		}
+
+		userSelector, err := userinfo.SelectorFromAnnotations(policy.GetAnnotations())
+		if err != nil {
+			klog.ErrorS(err, "skip policy with invalid user selector.", "policy", klog.KObj(policy))
+			return false
+		}
+		if !userSelector.Matches(req.UserInfo) {
+			return false
+		}
+
+		nsSelector, err := NamespaceSelectorFromAnnotations(policy.GetAnnotations())
+		if err != nil {
+			klog.ErrorS(err, "skip policy with invalid namespace selector.", "policy", klog.KObj(policy))
+			return false
+		}
+
+		return nsSelector == nil || matchNamespace(nsSelector, req.Namespace, namespaceLabels)
+	}
+}
+
+func matchNamespace(selector labels.Selector, namespace string, namespaceLabels NamespaceLabelsFunc) bool {
+	if namespace == "" || namespaceLabels == nil {
+		return false
+	}
+
+	nsLabels, ok := namespaceLabels(namespace)
+	if !ok {
+		klog.V(4).InfoS("namespace not found in cache.", "namespace", namespace)
+		return false
+	}
+
+	return selector.Matches(labels.Set(nsLabels))
+}
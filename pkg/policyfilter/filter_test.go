@@ -0,0 +1,116 @@
+package policyfilter
+
+import (
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/userinfo"
+)
+
+func TestNew(t *testing.T) {
+	namespaceLabels := func(namespace string) (map[string]string, bool) {
+		if namespace == "payments" {
+			return map[string]string{"team": "payments"}, true
+		}
+		return nil, namespace == "default"
+	}
+	newRequest := func(namespace, username string) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Namespace: namespace,
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+		}}
+	}
+	withSubresource := func(req admission.Request, resource, subresource string) admission.Request {
+		req.Resource = metav1.GroupVersionResource{Version: "v1", Resource: resource}
+		if resource == "deployments" {
+			req.Resource.Group = "apps"
+		}
+		req.SubResource = subresource
+		return req
+	}
+	newPolicy := func(annotations map[string]string) metav1.Object {
+		return &metav1.ObjectMeta{Name: "policy", Annotations: annotations}
+	}
+
+	tests := []struct {
+		name   string
+		req    admission.Request
+		policy metav1.Object
+		want   bool
+	}{
+		{
+			name:   "no selector",
+			req:    newRequest("", "admin"),
+			policy: newPolicy(nil),
+			want:   true,
+		},
+		{
+			name:   "namespace matched",
+			req:    newRequest("payments", "admin"),
+			policy: newPolicy(map[string]string{NamespaceSelectorAnnotation: `{"matchLabels":{"team":"payments"}}`}),
+			want:   true,
+		},
+		{
+			name:   "namespace not matched",
+			req:    newRequest("default", "admin"),
+			policy: newPolicy(map[string]string{NamespaceSelectorAnnotation: `{"matchLabels":{"team":"payments"}}`}),
+			want:   false,
+		},
+		{
+			name:   "cluster scoped",
+			req:    newRequest("", "admin"),
+			policy: newPolicy(map[string]string{NamespaceSelectorAnnotation: `{}`}),
+			want:   false,
+		},
+		{
+			name: "user not matched",
+			req:  newRequest("payments", "admin"),
+			policy: newPolicy(map[string]string{
+				NamespaceSelectorAnnotation: `{"matchLabels":{"team":"payments"}}`,
+				userinfo.SelectorAnnotation: `{"match":{"users":["ci"]}}`,
+			}),
+			want: false,
+		},
+		{
+			name:   "subresource",
+			req:    withSubresource(newRequest("payments", "admin"), "pods", "exec"),
+			policy: newPolicy(map[string]string{SubresourcesAnnotation: "deployments.apps/scale,pods/exec"}),
+			want:   true,
+		},
+		{
+			name:   "subresource not selected",
+			req:    withSubresource(newRequest("payments", "admin"), "pods", "exec"),
+			policy: newPolicy(nil),
+			want:   false,
+		},
+		{
+			name:   "subresource of other group",
+			req:    withSubresource(newRequest("payments", "admin"), "deployments", "scale"),
+			policy: newPolicy(map[string]string{SubresourcesAnnotation: "deployments.extensions/scale"}),
+			want:   false,
+		},
+		{
+			name:   "all subresources",
+			req:    withSubresource(newRequest("payments", "admin"), "deployments", "scale"),
+			policy: newPolicy(map[string]string{SubresourcesAnnotation: "*"}),
+			want:   true,
+		},
+		{
+			name:   "error",
+			req:    newRequest("payments", "admin"),
+			policy: newPolicy(map[string]string{NamespaceSelectorAnnotation: `{"matchExpressions":[{"key":"team","operator":"Bad"}]}`}),
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.req, namespaceLabels)(tt.policy); got != tt.want {
+				t.Errorf("New()() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
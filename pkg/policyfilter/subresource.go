@@ -0,0 +1,75 @@
+package policyfilter
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SubresourcesAnnotation is the annotation of policies holding a comma separated list of subresources
+// in resource[.group]/subresource format, e.g. `deployments.apps/scale,pods/exec`, or `*` for all subresources
+// of the selected resources. Policies without it only apply to requests of main resources.
+const SubresourcesAnnotation = "kinitiras.kcloudlabs.io/subresources"
+
+// AllSubresources matches every subresource.
+const AllSubresources = "*"
+
+// Subresource is a subresource of a resource, Group is empty for core resources or when any group is matched.
+type Subresource struct {
+	schema.GroupResource
+	Subresource string
+}
+
+// String returns the subresource in resource[.group]/subresource format.
+func (s Subresource) String() string {
+	if s.Subresource == AllSubresources && s.Resource == "" {
+		return AllSubresources
+	}
+	return s.GroupResource.String() + "/" + s.Subresource
+}
+
+// SubresourcesFromAnnotations returns the subresources stored in annotations.
+func SubresourcesFromAnnotations(annotations map[string]string) ([]Subresource, error) {
+	val, ok := annotations[SubresourcesAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	var subresources []Subresource
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item == AllSubresources {
+			subresources = append(subresources, Subresource{Subresource: AllSubresources})
+			continue
+		}
+
+		items := strings.Split(item, "/")
+		if len(items) != 2 || items[0] == "" || items[1] == "" {
+			return nil, fmt.Errorf("invalid annotation %s: invalid subresource(%v), should be in resource[.group]/subresource format", SubresourcesAnnotation, item)
+		}
+		subresources = append(subresources, Subresource{GroupResource: schema.ParseGroupResource(items[0]), Subresource: items[1]})
+	}
+
+	return subresources, nil
+}
+
+// matchSubresource returns true if a request of resource and subresource is selected by subresources.
+// Requests of main resources are always selected.
+func matchSubresource(subresources []Subresource, resource schema.GroupResource, subresource string) bool {
+	if subresource == "" {
+		return true
+	}
+
+	for _, s := range subresources {
+		if s.Resource == "" && s.Subresource == AllSubresources {
+			return true
+		}
+		if s.Resource == resource.Resource && (s.Group == "" || s.Group == resource.Group) &&
+			(s.Subresource == AllSubresources || s.Subresource == subresource) {
+			return true
+		}
+	}
+
+	return false
+}
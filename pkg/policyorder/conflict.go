@@ -0,0 +1,120 @@
+package policyorder
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Conflict means more than one policy wrote the same path of an object.
+type Conflict struct {
+	Path     string   `json:"path"`
+	Policies []string `json:"policies"`
+}
+
+// ConflictDetector records the paths written by policies and detects paths written more than once.
+// A path conflicts with its parents and children as well, e.g. /spec/containers and /spec/containers/0/image.
+type ConflictDetector struct {
+	writers map[string][]string
+}
+
+// NewConflictDetector returns an empty ConflictDetector.
+func NewConflictDetector() *ConflictDetector {
+	return &ConflictDetector{writers: map[string][]string{}}
+}
+
+// Add records paths written by policy.
+func (d *ConflictDetector) Add(policy string, paths []string) {
+	for _, path := range paths {
+		d.writers[path] = append(d.writers[path], policy)
+	}
+}
+
+// Conflicts returns paths written by more than one policy, sorted by path.
+func (d *ConflictDetector) Conflicts() []Conflict {
+	paths := make([]string, 0, len(d.writers))
+	for path := range d.writers {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var conflicts []Conflict
+	for _, path := range paths {
+		policies := append([]string{}, d.writers[path]...)
+		for _, other := range paths {
+			if other != path && strings.HasPrefix(path, other+"/") {
+				policies = append(policies, d.writers[other]...)
+			}
+		}
+		if policies = unique(policies); len(policies) > 1 {
+			conflicts = append(conflicts, Conflict{Path: path, Policies: policies})
+		}
+	}
+
+	return conflicts
+}
+
+// ChangedPaths returns json pointers of the fields which differ between before and after.
+// Lists with different length are reported as a whole. Paths are sorted.
+func ChangedPaths(before, after interface{}) []string {
+	var paths []string
+	changedPaths("", before, after, &paths)
+	sort.Strings(paths)
+	return paths
+}
+
+func changedPaths(path string, before, after interface{}, paths *[]string) {
+	switch a := after.(type) {
+	case map[string]interface{}:
+		b, ok := before.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for key := range union(a, b) {
+			changedPaths(path+"/"+escape(key), b[key], a[key], paths)
+		}
+		return
+	case []interface{}:
+		b, ok := before.([]interface{})
+		if !ok || len(a) != len(b) {
+			break
+		}
+		for i := range a {
+			changedPaths(path+"/"+strconv.Itoa(i), b[i], a[i], paths)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		*paths = append(*paths, path)
+	}
+}
+
+func union(a, b map[string]interface{}) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for key := range a {
+		keys[key] = struct{}{}
+	}
+	for key := range b {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
+
+// escape escapes a key as a json pointer reference token.
+func escape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+func unique(items []string) []string {
+	seen := make(map[string]bool, len(items))
+	ret := items[:0]
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
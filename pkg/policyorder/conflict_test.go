@@ -0,0 +1,53 @@
+package policyorder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangedPaths(t *testing.T) {
+	before := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{"app": "nginx"},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"image": "nginx:1.20"},
+			},
+			"volumes": []interface{}{},
+		},
+	}
+	after := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":      map[string]interface{}{"app": "nginx"},
+			"annotations": map[string]interface{}{"a/b": "c"},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"image": "nginx:1.21"},
+			},
+			"volumes": []interface{}{map[string]interface{}{"name": "data"}},
+		},
+	}
+
+	want := []string{"/metadata/annotations", "/spec/containers/0/image", "/spec/volumes"}
+	if got := ChangedPaths(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedPaths() = %v, want %v", got, want)
+	}
+	if got := ChangedPaths(after, after); len(got) != 0 {
+		t.Errorf("ChangedPaths() = %v, want none", got)
+	}
+}
+
+func TestConflictDetector(t *testing.T) {
+	d := NewConflictDetector()
+	d.Add("a", []string{"/spec/containers", "/metadata/labels/app"})
+	d.Add("b", []string{"/spec/containers/0/image"})
+	d.Add("c", []string{"/metadata/labels/team"})
+	d.Add("c", []string{"/metadata/labels/team"})
+
+	want := []Conflict{{Path: "/spec/containers/0/image", Policies: []string{"b", "a"}}}
+	if got := d.Conflicts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Conflicts() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+package policyorder
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// PriorityAnnotation is the annotation of override policies holding the priority as an integer. Default is 0.
+// It is an annotation since the policy CRDs are defined by k-cloud-labs/pkg, which have no priority field.
+const PriorityAnnotation = "kinitiras.kcloudlabs.io/priority"
+
+// Policy identifies an override policy to apply.
+type Policy struct {
+	// Cluster is true for ClusterOverridePolicy and false for OverridePolicy.
+	Cluster   bool
+	Namespace string
+	Name      string
+	Priority  int32
+	// Generation is the generation of the policy, it does not affect the order.
+	Generation int64
+}
+
+// String returns the policy in kind/name or kind/namespace/name format.
+func (p Policy) String() string {
+	if p.Cluster {
+		return "ClusterOverridePolicy/" + p.Name
+	}
+	return "OverridePolicy/" + p.Namespace + "/" + p.Name
+}
+
+// PriorityFromAnnotations returns the priority stored in annotations.
+func PriorityFromAnnotations(annotations map[string]string) (int32, error) {
+	val, ok := annotations[PriorityAnnotation]
+	if !ok {
+		return 0, nil
+	}
+
+	priority, err := strconv.ParseInt(val, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid annotation %s: %w", PriorityAnnotation, err)
+	}
+
+	return int32(priority), nil
+}
+
+// Sort sorts policies in the order they are applied, see Less.
+func Sort(policies []Policy) {
+	sort.SliceStable(policies, func(i, j int) bool {
+		return Less(policies[i], policies[j])
+	})
+}
+
+// Less returns whether a is applied before b:
+//   - policies with lower priority first, so a policy with higher priority overwrites the others;
+//   - ClusterOverridePolicy before OverridePolicy with the same priority;
+//   - by namespace and name in ascending.
+func Less(a, b Policy) bool {
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	if a.Cluster != b.Cluster {
+		return a.Cluster
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
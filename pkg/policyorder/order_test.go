@@ -0,0 +1,67 @@
+package policyorder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSort(t *testing.T) {
+	policies := []Policy{
+		{Namespace: "default", Name: "b"},
+		{Namespace: "default", Name: "a", Priority: 10},
+		{Cluster: true, Name: "z"},
+		{Namespace: "default", Name: "a"},
+		{Cluster: true, Name: "y", Priority: -1},
+	}
+	want := []string{
+		"ClusterOverridePolicy/y",
+		"ClusterOverridePolicy/z",
+		"OverridePolicy/default/a",
+		"OverridePolicy/default/b",
+		"OverridePolicy/default/a",
+	}
+
+	Sort(policies)
+	got := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		got = append(got, policy.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Sort() = %v, want %v", got, want)
+	}
+}
+
+func TestPriorityFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        int32
+		wantErr     bool
+	}{
+		{
+			name: "default",
+			want: 0,
+		},
+		{
+			name:        "1",
+			annotations: map[string]string{PriorityAnnotation: "-5"},
+			want:        -5,
+		},
+		{
+			name:        "error",
+			annotations: map[string]string{PriorityAnnotation: "high"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PriorityFromAnnotations(tt.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PriorityFromAnnotations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("PriorityFromAnnotations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
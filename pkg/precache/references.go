@@ -0,0 +1,78 @@
+package precache
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// refFromK8s is the value of `from` of a reference which reads an object from cluster, e.g.
+	//   valueRef:
+	//     from: k8s
+	//     k8s:
+	//       apiVersion: apps/v1
+	//       kind: Deployment
+	refFromK8s = "k8s"
+)
+
+// ReferencedResources returns the kinds of resources read from cluster by the policy,
+// found in the `valueRef`, `dataRef` and similar references of its rules.
+func ReferencedResources(policy *unstructured.Unstructured) []schema.GroupVersionKind {
+	spec, ok := policy.Object["spec"]
+	if !ok {
+		return nil
+	}
+
+	seen := make(map[schema.GroupVersionKind]struct{})
+	var result []schema.GroupVersionKind
+	walk(spec, func(gvk schema.GroupVersionKind) {
+		if _, ok := seen[gvk]; ok {
+			return
+		}
+		seen[gvk] = struct{}{}
+		result = append(result, gvk)
+	})
+
+	return result
+}
+
+// walk visits every reference to a cluster object in v, whatever its location in the policy.
+func walk(v interface{}, visit func(schema.GroupVersionKind)) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if gvk, ok := k8sReference(value); ok {
+			visit(gvk)
+		}
+		for _, item := range value {
+			walk(item, visit)
+		}
+	case []interface{}:
+		for _, item := range value {
+			walk(item, visit)
+		}
+	}
+}
+
+func k8sReference(ref map[string]interface{}) (schema.GroupVersionKind, bool) {
+	if from, _ := ref["from"].(string); from != refFromK8s {
+		return schema.GroupVersionKind{}, false
+	}
+
+	selector, ok := ref[refFromK8s].(map[string]interface{})
+	if !ok {
+		return schema.GroupVersionKind{}, false
+	}
+
+	apiVersion, _ := selector["apiVersion"].(string)
+	kind, _ := selector["kind"].(string)
+	if apiVersion == "" || kind == "" {
+		return schema.GroupVersionKind{}, false
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return schema.GroupVersionKind{}, false
+	}
+
+	return gv.WithKind(kind), true
+}
@@ -0,0 +1,264 @@
+package precache
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// Registry registers resources to be cached, it is implemented by the dynamic resource lister.
+type Registry interface {
+	RegisterNewResource(waitForSync bool, gvks ...schema.GroupVersionKind) error
+}
+
+// unregisterer is implemented by registries which can stop caching resources. The dynamic resource lister of
+// k-cloud-labs/pkg does not implement it yet, so resources stay cached until restart.
+type unregisterer interface {
+	UnregisterResource(gvks ...schema.GroupVersionKind) error
+}
+
+// Tracker keeps resources referred by policies cached in a Registry.
+// A resource is registered once the first policy refers it and unregistered, when the registry
+// supports it, once no policy refers it anymore. Pinned resources are never unregistered.
+// Resources are registered in the background, Pending returns the ones whose cache has not synced yet.
+type Tracker struct {
+	registry Registry
+
+	mu sync.Mutex
+	// refs holds the resources referred by each policy, keyed by kind/namespace/name.
+	refs map[string][]schema.GroupVersionKind
+	// counts holds the number of policies referring each resource.
+	counts map[schema.GroupVersionKind]int
+	pinned map[schema.GroupVersionKind]struct{}
+	// pending holds the number of registrations of each resource, until its cache has synced.
+	pending map[schema.GroupVersionKind]int
+}
+
+// NewTracker builds a Tracker registering resources to registry.
+// The pinned resources are expected to be registered by the caller already.
+func NewTracker(registry Registry, pinned ...schema.GroupVersionKind) *Tracker {
+	t := &Tracker{
+		registry: registry,
+		refs:     make(map[string][]schema.GroupVersionKind),
+		counts:   make(map[schema.GroupVersionKind]int),
+		pinned:   make(map[schema.GroupVersionKind]struct{}, len(pinned)),
+		pending:  make(map[schema.GroupVersionKind]int),
+	}
+	for _, gvk := range pinned {
+		t.pinned[gvk] = struct{}{}
+	}
+
+	return t
+}
+
+// SetPinned replaces the resources which stay cached whether policies refer them or not,
+// e.g. the ones given by --pre-cache-resources.
+func (t *Tracker) SetPinned(gvks []schema.GroupVersionKind) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	old := t.pinned
+	t.pinned = make(map[schema.GroupVersionKind]struct{}, len(gvks))
+	for _, gvk := range gvks {
+		t.pinned[gvk] = struct{}{}
+	}
+
+	var added, removed []schema.GroupVersionKind
+	for gvk := range t.pinned {
+		if _, ok := old[gvk]; !ok && t.counts[gvk] == 0 {
+			added = append(added, gvk)
+		}
+	}
+	for gvk := range old {
+		if _, ok := t.pinned[gvk]; !ok && t.counts[gvk] == 0 {
+			removed = append(removed, gvk)
+		}
+	}
+
+	t.register(added)
+	t.unregister(removed)
+}
+
+// Resources returns all resources which are kept cached.
+func (t *Tracker) Resources() []schema.GroupVersionKind {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]schema.GroupVersionKind, 0, len(t.counts)+len(t.pinned))
+	for gvk := range t.pinned {
+		result = append(result, gvk)
+	}
+	for gvk := range t.counts {
+		if _, ok := t.pinned[gvk]; !ok {
+			result = append(result, gvk)
+		}
+	}
+
+	return result
+}
+
+// Pending returns the resources being registered whose cache has not synced yet.
+func (t *Tracker) Pending() []schema.GroupVersionKind {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]schema.GroupVersionKind, 0, len(t.pending))
+	for gvk := range t.pending {
+		result = append(result, gvk)
+	}
+
+	return result
+}
+
+// EventHandler returns the handler to add to policy informers.
+func (t *Tracker) EventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if policy, ok := obj.(*unstructured.Unstructured); ok {
+				t.Update(policy)
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			if policy, ok := obj.(*unstructured.Unstructured); ok {
+				t.Update(policy)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if policy, ok := obj.(*unstructured.Unstructured); ok {
+				t.Delete(policy)
+			}
+		},
+	}
+}
+
+// Update records the resources referred by policy.
+func (t *Tracker) Update(policy *unstructured.Unstructured) {
+	gvks := ReferencedResources(policy)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := policyKey(policy)
+	old := t.refs[key]
+	// acquire before release, so resources still referred are neither registered nor unregistered
+	added := t.acquire(gvks)
+	removed := t.release(old)
+	if len(gvks) == 0 {
+		delete(t.refs, key)
+	} else {
+		t.refs[key] = gvks
+	}
+	t.unregister(removed)
+	t.register(added)
+}
+
+// Delete forgets the resources referred by policy.
+func (t *Tracker) Delete(policy *unstructured.Unstructured) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := policyKey(policy)
+	removed := t.release(t.refs[key])
+	delete(t.refs, key)
+	t.unregister(removed)
+}
+
+// acquire increases reference counts, returns resources referred for the first time.
+func (t *Tracker) acquire(gvks []schema.GroupVersionKind) []schema.GroupVersionKind {
+	var added []schema.GroupVersionKind
+	for _, gvk := range gvks {
+		t.counts[gvk]++
+		if _, ok := t.pinned[gvk]; !ok && t.counts[gvk] == 1 {
+			added = append(added, gvk)
+		}
+	}
+
+	return added
+}
+
+// release decreases reference counts, returns resources not referred anymore.
+func (t *Tracker) release(gvks []schema.GroupVersionKind) []schema.GroupVersionKind {
+	var removed []schema.GroupVersionKind
+	for _, gvk := range gvks {
+		t.counts[gvk]--
+		if t.counts[gvk] > 0 {
+			continue
+		}
+
+		delete(t.counts, gvk)
+		if _, ok := t.pinned[gvk]; !ok {
+			removed = append(removed, gvk)
+		}
+	}
+
+	return removed
+}
+
+// register registers gvks in the background and waits for their cache to sync, it is called with mu held.
+func (t *Tracker) register(gvks []schema.GroupVersionKind) {
+	if len(gvks) == 0 {
+		return
+	}
+
+	for _, gvk := range gvks {
+		t.pending[gvk]++
+	}
+	klog.InfoS("register resources referred by policies to lister.", "resources", gvks)
+	go func() {
+		err := t.registry.RegisterNewResource(true, gvks...)
+		if err != nil {
+			// resources failing to register do not hold readiness, policies referring them fail instead
+			klog.ErrorS(err, "failed to register resource to lister", "resources", gvks)
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		var removed []schema.GroupVersionKind
+		for _, gvk := range gvks {
+			if t.pending[gvk]--; t.pending[gvk] > 0 {
+				continue
+			}
+			delete(t.pending, gvk)
+			if _, ok := t.pinned[gvk]; !ok && t.counts[gvk] == 0 && err == nil {
+				// not referred anymore while it was registering
+				removed = append(removed, gvk)
+			}
+		}
+		t.unregister(removed)
+	}()
+}
+
+func (t *Tracker) unregister(gvks []schema.GroupVersionKind) {
+	// resources being registered are unregistered once the registration is done
+	ready := gvks[:0:0]
+	for _, gvk := range gvks {
+		if t.pending[gvk] == 0 {
+			ready = append(ready, gvk)
+		}
+	}
+	gvks = ready
+	if len(gvks) == 0 {
+		return
+	}
+
+	u, ok := t.registry.(unregisterer)
+	if !ok {
+		klog.V(2).InfoS("resources not referred by policies anymore, keep them cached until restart.", "resources", gvks)
+		return
+	}
+
+	klog.InfoS("unregister resources not referred by policies anymore.", "resources", gvks)
+	if err := u.UnregisterResource(gvks...); err != nil {
+		klog.ErrorS(err, "failed to unregister resource from lister", "resources", gvks)
+	}
+}
+
+func policyKey(policy *unstructured.Unstructured) string {
+	return policy.GetKind() + "/" + policy.GetNamespace() + "/" + policy.GetName()
+}
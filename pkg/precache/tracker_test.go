@@ -0,0 +1,184 @@
+package precache
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var deploymentGVK = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+type fakeRegistry struct {
+	mu     sync.Mutex
+	cached map[schema.GroupVersionKind]struct{}
+	// synced blocks registration until it is closed, if it is set
+	synced chan struct{}
+}
+
+func (f *fakeRegistry) RegisterNewResource(_ bool, gvks ...schema.GroupVersionKind) error {
+	if f.synced != nil {
+		<-f.synced
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, gvk := range gvks {
+		f.cached[gvk] = struct{}{}
+	}
+	return nil
+}
+
+func (f *fakeRegistry) UnregisterResource(gvks ...schema.GroupVersionKind) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, gvk := range gvks {
+		delete(f.cached, gvk)
+	}
+	return nil
+}
+
+func newPolicy(name string, refs ...map[string]interface{}) *unstructured.Unstructured {
+	rules := make([]interface{}, 0, len(refs))
+	for _, ref := range refs {
+		rules = append(rules, map[string]interface{}{
+			"overriders": map[string]interface{}{
+				"template": map[string]interface{}{
+					"valueRef": ref,
+				},
+			},
+		})
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "policy.kcloudlabs.io/v1alpha1",
+		"kind":       "ClusterOverridePolicy",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"overrideRules": rules},
+	}}
+}
+
+func k8sRef(apiVersion, kind string) map[string]interface{} {
+	return map[string]interface{}{
+		"from": "k8s",
+		"path": "/metadata/name",
+		"k8s":  map[string]interface{}{"apiVersion": apiVersion, "kind": kind},
+	}
+}
+
+func TestReferencedResources(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *unstructured.Unstructured
+		want   []schema.GroupVersionKind
+	}{
+		{
+			name:   "no reference",
+			policy: newPolicy("a"),
+			want:   nil,
+		},
+		{
+			name:   "current reference",
+			policy: newPolicy("a", map[string]interface{}{"from": "current", "path": "/spec/restartPolicy"}),
+			want:   nil,
+		},
+		{
+			name:   "k8s references",
+			policy: newPolicy("a", k8sRef("apps/v1", "Deployment"), k8sRef("apps/v1", "Deployment"), k8sRef("v1", "Pod")),
+			want:   []schema.GroupVersionKind{deploymentGVK, {Version: "v1", Kind: "Pod"}},
+		},
+		{
+			name:   "invalid reference",
+			policy: newPolicy("a", k8sRef("apps/v1/v2", "Deployment"), k8sRef("v1", "")),
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReferencedResources(tt.policy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReferencedResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTracker(t *testing.T) {
+	registry := &fakeRegistry{cached: map[schema.GroupVersionKind]struct{}{}}
+	tracker := NewTracker(registry)
+	podGVK := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+
+	tracker.SetPinned([]schema.GroupVersionKind{podGVK})
+	tracker.Update(newPolicy("a", k8sRef("apps/v1", "Deployment"), k8sRef("v1", "Pod")))
+	tracker.Update(newPolicy("b", k8sRef("apps/v1", "Deployment")))
+	assertCached(t, registry, podGVK, deploymentGVK)
+
+	tracker.Delete(newPolicy("a"))
+	assertCached(t, registry, podGVK, deploymentGVK)
+
+	// resync of a policy whose references are unchanged
+	tracker.Update(newPolicy("b", k8sRef("apps/v1", "Deployment")))
+	tracker.Update(newPolicy("b", k8sRef("apps/v1", "Deployment")))
+	assertCached(t, registry, podGVK, deploymentGVK)
+
+	// reference removed by update
+	tracker.Update(newPolicy("b"))
+	assertCached(t, registry, podGVK)
+
+	tracker.SetPinned(nil)
+	assertCached(t, registry)
+	if got := tracker.Resources(); len(got) != 0 {
+		t.Errorf("Resources() = %v, want empty", got)
+	}
+}
+
+func TestTracker_Pending(t *testing.T) {
+	registry := &fakeRegistry{cached: map[schema.GroupVersionKind]struct{}{}, synced: make(chan struct{})}
+	tracker := NewTracker(registry)
+
+	tracker.Update(newPolicy("a", k8sRef("apps/v1", "Deployment")))
+	if got := tracker.Pending(); !reflect.DeepEqual(got, []schema.GroupVersionKind{deploymentGVK}) {
+		t.Errorf("Pending() = %v, want %v", got, []schema.GroupVersionKind{deploymentGVK})
+	}
+
+	// not referred anymore while it is registering
+	tracker.Delete(newPolicy("a"))
+	close(registry.synced)
+	err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+		return len(tracker.Pending()) == 0, nil
+	})
+	if err != nil {
+		t.Errorf("Pending() = %v, want empty", tracker.Pending())
+	}
+	assertCached(t, registry)
+}
+
+// assertCached waits for resources registered in the background to be cached.
+func assertCached(t *testing.T, registry *fakeRegistry, want ...schema.GroupVersionKind) {
+	t.Helper()
+
+	wantStr := make([]string, 0, len(want))
+	for _, gvk := range want {
+		wantStr = append(wantStr, gvk.String())
+	}
+	sort.Strings(wantStr)
+
+	var got []string
+	err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+		registry.mu.Lock()
+		defer registry.mu.Unlock()
+		got = make([]string, 0, len(registry.cached))
+		for gvk := range registry.cached {
+			got = append(got, gvk.String())
+		}
+		sort.Strings(got)
+		return reflect.DeepEqual(got, wantStr), nil
+	})
+	if err != nil {
+		t.Errorf("cached = %v, want %v", got, wantStr)
+	}
+}
@@ -0,0 +1,37 @@
+package userinfo
+
+import (
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// CueField is the field of metadata of the object passed to CUE programs of policies holding the user of the
+// admission request, e.g. `object.metadata.userInfo.username`. ObjectMeta has no such field, so it never
+// collides with a field of the object.
+const CueField = "userInfo"
+
+// SetInObject sets userInfo to CueField of the metadata of obj, so CUE programs of policies are given the user
+// of the request as input by the object parameter, without changing the programs. The returned func removes it,
+// it must be called before obj is patched or stored.
+func SetInObject(obj *unstructured.Unstructured, userInfo authenticationv1.UserInfo) (func(), error) {
+	value, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&userInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert user info: %w", err)
+	}
+	// objects of some subresources, e.g. PodExecOptions, have no metadata
+	_, hasMetadata := obj.Object["metadata"]
+	if err := unstructured.SetNestedField(obj.Object, value, "metadata", CueField); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if !hasMetadata {
+			delete(obj.Object, "metadata")
+			return
+		}
+		unstructured.RemoveNestedField(obj.Object, "metadata", CueField)
+	}, nil
+}
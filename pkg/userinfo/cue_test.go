@@ -0,0 +1,47 @@
+package userinfo
+
+import (
+	"reflect"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSetInObject(t *testing.T) {
+	userInfo := authenticationv1.UserInfo{Username: "ci", Groups: []string{"system:authenticated"}}
+	want := map[string]interface{}{"username": "ci", "groups": []interface{}{"system:authenticated"}}
+
+	tests := []struct {
+		name string
+		obj  map[string]interface{}
+	}{
+		{
+			name: "1",
+			obj:  map[string]interface{}{"metadata": map[string]interface{}{"name": "a"}},
+		},
+		{
+			name: "without metadata",
+			obj:  map[string]interface{}{"kind": "PodExecOptions", "command": []interface{}{"sh"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: tt.obj}
+			original := obj.DeepCopy()
+
+			remove, err := SetInObject(obj, userInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _, _ := unstructured.NestedMap(obj.Object, "metadata", CueField); !reflect.DeepEqual(got, want) {
+				t.Errorf("userInfo = %v, want %v", got, want)
+			}
+
+			remove()
+			if !reflect.DeepEqual(obj, original) {
+				t.Errorf("object = %v, want %v", obj, original)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package userinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+)
+
+// SelectorAnnotation is the annotation of policies holding a json encoded Selector.
+// Policies without it apply to requests of all users.
+const SelectorAnnotation = "kinitiras.kcloudlabs.io/user-selector"
+
+// Selector selects admission requests by the user who sent them.
+type Selector struct {
+	// Match selects the requests a policy applies to. Requests of all users are selected if it is empty.
+	// +optional
+	Match *Subjects `json:"match,omitempty"`
+	// Exclude filters out requests selected by Match.
+	// +optional
+	Exclude *Subjects `json:"exclude,omitempty"`
+}
+
+// Subjects is a set of users. A request matches if any of the fields matches.
+type Subjects struct {
+	// Users is a list of usernames.
+	// +optional
+	Users []string `json:"users,omitempty"`
+	// Groups is a list of user groups.
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+	// ServiceAccounts is a list of service accounts in namespace/name format.
+	// Name can be `*` to match all service accounts of the namespace.
+	// +optional
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+}
+
+// SelectorFromAnnotations returns the Selector stored in annotations, or nil if there is none.
+func SelectorFromAnnotations(annotations map[string]string) (*Selector, error) {
+	val, ok := annotations[SelectorAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	selector := &Selector{}
+	if err := json.Unmarshal([]byte(val), selector); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %w", SelectorAnnotation, err)
+	}
+	if err := selector.validate(); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %w", SelectorAnnotation, err)
+	}
+
+	return selector, nil
+}
+
+// Matches returns true if the request sent by userInfo is selected.
+// A nil Selector matches all users.
+func (s *Selector) Matches(userInfo authenticationv1.UserInfo) bool {
+	if s == nil {
+		return true
+	}
+	if s.Exclude.matches(userInfo) {
+		return false
+	}
+
+	return s.Match == nil || s.Match.matches(userInfo)
+}
+
+func (s *Selector) validate() error {
+	for _, subjects := range []*Subjects{s.Match, s.Exclude} {
+		if subjects == nil {
+			continue
+		}
+		for _, sa := range subjects.ServiceAccounts {
+			if items := strings.Split(sa, "/"); len(items) != 2 || items[0] == "" || items[1] == "" {
+				return fmt.Errorf("invalid service account(%v), should be in namespace/name format", sa)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Subjects) matches(userInfo authenticationv1.UserInfo) bool {
+	if s == nil {
+		return false
+	}
+	if sets.NewString(s.Users...).Has(userInfo.Username) || sets.NewString(s.Groups...).HasAny(userInfo.Groups...) {
+		return true
+	}
+
+	namespace, name, err := serviceaccount.SplitUsername(userInfo.Username)
+	if err != nil {
+		return false
+	}
+	accounts := sets.NewString(s.ServiceAccounts...)
+	return accounts.Has(namespace+"/"+name) || accounts.Has(namespace+"/*")
+}
@@ -0,0 +1,109 @@
+package userinfo
+
+import (
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func TestSelector_Matches(t *testing.T) {
+	annotations := map[string]string{
+		SelectorAnnotation: `{"match":{"users":["ci"],"serviceAccounts":["ci/*","argocd/argocd-server"]},"exclude":{"groups":["system:masters"]}}`,
+	}
+	selector, err := SelectorFromAnnotations(annotations)
+	if err != nil {
+		t.Fatalf("SelectorFromAnnotations() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		selector *Selector
+		userInfo authenticationv1.UserInfo
+		want     bool
+	}{
+		{
+			name:     "nil",
+			selector: nil,
+			userInfo: authenticationv1.UserInfo{Username: "admin"},
+			want:     true,
+		},
+		{
+			name:     "user",
+			selector: selector,
+			userInfo: authenticationv1.UserInfo{Username: "ci"},
+			want:     true,
+		},
+		{
+			name:     "service account wildcard",
+			selector: selector,
+			userInfo: authenticationv1.UserInfo{Username: "system:serviceaccount:ci:runner"},
+			want:     true,
+		},
+		{
+			name:     "service account",
+			selector: selector,
+			userInfo: authenticationv1.UserInfo{Username: "system:serviceaccount:argocd:argocd-server"},
+			want:     true,
+		},
+		{
+			name:     "excluded",
+			selector: selector,
+			userInfo: authenticationv1.UserInfo{Username: "ci", Groups: []string{"system:masters"}},
+			want:     false,
+		},
+		{
+			name:     "not matched",
+			selector: selector,
+			userInfo: authenticationv1.UserInfo{Username: "system:serviceaccount:argocd:argocd-repo-server"},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.selector.Matches(tt.userInfo); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectorFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantNil     bool
+		wantErr     bool
+	}{
+		{
+			name:    "none",
+			wantNil: true,
+		},
+		{
+			name:        "1",
+			annotations: map[string]string{SelectorAnnotation: `{"exclude":{"users":["admin"]}}`},
+		},
+		{
+			name:        "error",
+			annotations: map[string]string{SelectorAnnotation: `{"match":`},
+			wantNil:     true,
+			wantErr:     true,
+		},
+		{
+			name:        "invalid service account",
+			annotations: map[string]string{SelectorAnnotation: `{"match":{"serviceAccounts":["ci"]}}`},
+			wantNil:     true,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectorFromAnnotations(tt.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SelectorFromAnnotations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != tt.wantNil {
+				t.Errorf("SelectorFromAnnotations() = %v, wantNil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package filewatcher
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+)
+
+// Watch calls onChange every time the file located at path is written, created or replaced.
+// The parent directory is watched instead of the file itself, so files mounted from
+// ConfigMaps, which are updated by swapping symlinks, are supported as well.
+// It blocks until ctx is done.
+func Watch(ctx context.Context, path string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	realPath, _ := filepath.EvalSymlinks(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			// ConfigMap volumes replace the `..data` symlink, so the target of path changes
+			// while no event is emitted for path itself.
+			currentPath, _ := filepath.EvalSymlinks(path)
+			if filepath.Clean(event.Name) != path && currentPath == realPath {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 && currentPath == realPath {
+				continue
+			}
+
+			realPath = currentPath
+			klog.V(2).InfoS("watched file changed.", "path", path, "event", event.String())
+			onChange()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			klog.ErrorS(err, "file watcher error.", "path", path)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	evanphxjsonpatch "github.com/evanphx/json-patch"
+	"gomodules.xyz/jsonpatch/v2"
+)
+
+// CreatePatch returns the json patch operations which turn original into current. Both are expected to be
+// decoded from json, e.g. Object of unstructured.Unstructured.
+//
+// Unlike diffing marshaled objects, numbers are compared by value, so float64 and int64 holding the same
+// number do not produce a patch. Adding null, e.g. `creationTimestamp: null`, is skipped since it is equal
+// to an absent field for Kubernetes objects, so is removing null. Lists are patched item by item. Items added
+// or removed at one place, e.g. a container inserted at the front, are added or removed at that place, so the
+// items after it are not replaced. Items are never moved.
+func CreatePatch(original, current map[string]interface{}) ([]jsonpatch.JsonPatchOperation, error) {
+	var ops []jsonpatch.JsonPatchOperation
+	if err := diff("", original, current, &ops); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+func diff(path string, a, b interface{}, ops *[]jsonpatch.JsonPatchOperation) error {
+	switch bv := b.(type) {
+	case map[string]interface{}:
+		if av, ok := a.(map[string]interface{}); ok {
+			return diffMap(path, av, bv, ops)
+		}
+	case []interface{}:
+		if av, ok := a.([]interface{}); ok {
+			return diffList(path, av, bv, ops)
+		}
+	}
+
+	equal, err := equalValues(a, b)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if equal {
+		return nil
+	}
+	if b == nil {
+		*ops = append(*ops, jsonpatch.NewOperation("remove", path, nil))
+		return nil
+	}
+	*ops = append(*ops, jsonpatch.NewOperation("replace", path, b))
+	return nil
+}
+
+func diffMap(path string, a, b map[string]interface{}, ops *[]jsonpatch.JsonPatchOperation) error {
+	for _, key := range sortedKeys(a) {
+		if _, ok := b[key]; !ok && a[key] != nil {
+			*ops = append(*ops, jsonpatch.NewOperation("remove", path+"/"+escape(key), nil))
+		}
+	}
+
+	for _, key := range sortedKeys(b) {
+		av, ok := a[key]
+		if !ok {
+			if b[key] != nil {
+				*ops = append(*ops, jsonpatch.NewOperation("add", path+"/"+escape(key), b[key]))
+			}
+			continue
+		}
+		if err := diff(path+"/"+escape(key), av, b[key], ops); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func diffList(path string, a, b []interface{}, ops *[]jsonpatch.JsonPatchOperation) error {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+
+	// items before and after the added or removed ones are kept
+	prefix := 0
+	for ; prefix < n; prefix++ {
+		if equal, err := equalValues(a[prefix], b[prefix]); err != nil {
+			return fmt.Errorf("%s/%d: %w", path, prefix, err)
+		} else if !equal {
+			break
+		}
+	}
+	suffix := 0
+	for ; suffix < n-prefix; suffix++ {
+		if equal, err := equalValues(a[len(a)-1-suffix], b[len(b)-1-suffix]); err != nil {
+			return fmt.Errorf("%s/%d: %w", path, len(a)-1-suffix, err)
+		} else if !equal {
+			break
+		}
+	}
+
+	// the items between are patched item by item, then the extra items are added or removed at their end
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n = len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		if err := diff(path+"/"+strconv.Itoa(prefix+i), a[i], b[i], ops); err != nil {
+			return err
+		}
+	}
+
+	for i := n; i < len(b); i++ {
+		*ops = append(*ops, jsonpatch.NewOperation("add", path+"/"+strconv.Itoa(prefix+i), b[i]))
+	}
+	// remove from the end, so indexes of the items to remove are not shifted
+	for i := len(a) - 1; i >= n; i-- {
+		*ops = append(*ops, jsonpatch.NewOperation("remove", path+"/"+strconv.Itoa(prefix+i), nil))
+	}
+
+	return nil
+}
+
+// Apply applies the json patch operations ops to a copy of obj and returns it.
+func Apply(obj map[string]interface{}, ops []jsonpatch.JsonPatchOperation) (map[string]interface{}, error) {
+	doc, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(ops)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := evanphxjsonpatch.DecodePatch(data)
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = patch.Apply(doc); err != nil {
+		return nil, err
+	}
+
+	var ret map[string]interface{}
+	if err := json.Unmarshal(doc, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// equalValues compares values decoded from json, numbers are compared by value.
+func equalValues(a, b interface{}) (bool, error) {
+	switch av := a.(type) {
+	case nil:
+		return b == nil, nil
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv, nil
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv, nil
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false, nil
+		}
+		for key, value := range av {
+			other, ok := bv[key]
+			if !ok {
+				return false, nil
+			}
+			if equal, err := equalValues(value, other); err != nil || !equal {
+				return false, err
+			}
+		}
+		return true, nil
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false, nil
+		}
+		for i := range av {
+			if equal, err := equalValues(av[i], bv[i]); err != nil || !equal {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	an, err := number(a)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := b.(string); ok || b == nil {
+		return false, nil
+	}
+	bn, err := number(b)
+	if err != nil {
+		return false, nil
+	}
+	return an == bn, nil
+}
+
+// number converts a number decoded from json to float64, integers out of the exact range of float64
+// are compared by their string form.
+func number(v interface{}) (interface{}, error) {
+	switch n := v.(type) {
+	case int64:
+		if n > 1<<53 || n < -(1<<53) {
+			return strconv.FormatInt(n, 10), nil
+		}
+		return float64(n), nil
+	case int:
+		return number(int64(n))
+	case int32:
+		return float64(n), nil
+	case float64:
+		if n == math.Trunc(n) && math.Abs(n) > 1<<53 {
+			return strconv.FormatFloat(n, 'f', -1, 64), nil
+		}
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return number(i)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return number(f)
+	case bool, string, map[string]interface{}, []interface{}:
+		return nil, fmt.Errorf("unexpected type %T", v)
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escape escapes a key as a json pointer reference token.
+func escape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
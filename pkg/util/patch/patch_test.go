@@ -0,0 +1,113 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+
+	"gomodules.xyz/jsonpatch/v2"
+)
+
+func TestCreatePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		original map[string]interface{}
+		current  map[string]interface{}
+		want     []jsonpatch.JsonPatchOperation
+		wantErr  bool
+	}{
+		{
+			name: "1",
+			original: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":              "nginx",
+					"creationTimestamp": nil,
+					"labels":            map[string]interface{}{"app": "nginx", "a/b": "c"},
+				},
+				"spec": map[string]interface{}{
+					"replicas": int64(1),
+					"containers": []interface{}{
+						map[string]interface{}{"image": "nginx:1.20"},
+					},
+				},
+			},
+			current: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":        "nginx",
+					"labels":      map[string]interface{}{"app": "web"},
+					"annotations": map[string]interface{}{"owner": "team"},
+				},
+				"spec": map[string]interface{}{
+					"replicas": float64(1),
+					"containers": []interface{}{
+						map[string]interface{}{"image": "nginx:1.21"},
+						map[string]interface{}{"image": "sidecar"},
+					},
+					"nodeName": nil,
+				},
+			},
+			want: []jsonpatch.JsonPatchOperation{
+				{Operation: "add", Path: "/metadata/annotations", Value: map[string]interface{}{"owner": "team"}},
+				{Operation: "remove", Path: "/metadata/labels/a~1b"},
+				{Operation: "replace", Path: "/metadata/labels/app", Value: "web"},
+				{Operation: "replace", Path: "/spec/containers/0/image", Value: "nginx:1.21"},
+				{Operation: "add", Path: "/spec/containers/1", Value: map[string]interface{}{"image": "sidecar"}},
+			},
+		},
+		{
+			name: "2",
+			original: map[string]interface{}{
+				"args": []interface{}{"a", "b", "c"},
+			},
+			current: map[string]interface{}{
+				"args": []interface{}{"b"},
+			},
+			want: []jsonpatch.JsonPatchOperation{
+				{Operation: "replace", Path: "/args/0", Value: "b"},
+				{Operation: "remove", Path: "/args/2"},
+				{Operation: "remove", Path: "/args/1"},
+			},
+		},
+		{
+			name: "insert at the front",
+			original: map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "a"},
+					map[string]interface{}{"name": "b"},
+				},
+			},
+			current: map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "sidecar"},
+					map[string]interface{}{"name": "a"},
+					map[string]interface{}{"name": "b"},
+				},
+			},
+			want: []jsonpatch.JsonPatchOperation{
+				{Operation: "add", Path: "/containers/0", Value: map[string]interface{}{"name": "sidecar"}},
+			},
+		},
+		{
+			name:     "3",
+			original: map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+			current:  map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+		},
+		{
+			name:     "error",
+			original: map[string]interface{}{"spec": struct{}{}},
+			current:  map[string]interface{}{"spec": "a"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CreatePatch(tt.original, tt.current)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreatePatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CreatePatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
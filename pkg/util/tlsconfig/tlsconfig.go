@@ -0,0 +1,104 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// Version converts a human-readable TLS version, e.g. "1.2", to the value accepted by tls.Config.
+func Version(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("invalid TLS version %q: expects 1.0, 1.1, 1.2 or 1.3", version)
+	}
+}
+
+// ProfileFIPS is the profile limiting cipher suites and curves to the ones approved by FIPS 140-2.
+const ProfileFIPS = "FIPS"
+
+var (
+	fipsCipherSuites = []string{
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	}
+	fipsCurvePreferences = []string{"P256", "P384"}
+
+	curves = map[string]tls.CurveID{
+		"X25519": tls.X25519,
+		"P256":   tls.CurveP256,
+		"P384":   tls.CurveP384,
+		"P521":   tls.CurveP521,
+	}
+)
+
+// Config contains the TLS settings shared by the webhook and metrics servers.
+type Config struct {
+	// MinVersion is the minimum TLS version, e.g. tls.VersionTLS12.
+	MinVersion uint16
+	// CipherSuites are the cipher suites of TLS 1.2 and below, nil means the default of Go.
+	// Cipher suites of TLS 1.3 are not configurable.
+	CipherSuites []uint16
+	// CurvePreferences are the elliptic curves used in ECDHE handshakes, nil means the default of Go.
+	CurvePreferences []tls.CurveID
+}
+
+// Apply sets the settings to cfg.
+func (c Config) Apply(cfg *tls.Config) {
+	cfg.MinVersion = c.MinVersion
+	cfg.CipherSuites = c.CipherSuites
+	cfg.CurvePreferences = c.CurvePreferences
+}
+
+// CipherSuites converts names of cipher suites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, to their IDs.
+// Only the cipher suites returned by tls.CipherSuites are accepted, insecure ones are rejected.
+func CipherSuites(names []string) ([]uint16, error) {
+	supported := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		supported[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := supported[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// CurvePreferences converts names of elliptic curves to their IDs. Possible names: X25519, P256, P384, P521.
+func CurvePreferences(names []string) ([]tls.CurveID, error) {
+	ids := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		id, ok := curves[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q: expects X25519, P256, P384 or P521", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Profile returns names of the cipher suites and curves of a profile, empty name means the default of Go.
+func Profile(name string) (cipherSuites, curvePreferences []string, err error) {
+	switch name {
+	case "":
+		return nil, nil, nil
+	case ProfileFIPS:
+		return append([]string(nil), fipsCipherSuites...), append([]string(nil), fipsCurvePreferences...), nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported TLS profile %q: expects %s or empty", name, ProfileFIPS)
+	}
+}
@@ -0,0 +1,72 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    uint16
+		wantErr bool
+	}{
+		{name: "1", version: "1.2", want: tls.VersionTLS12},
+		{name: "2", version: "1.3", want: tls.VersionTLS13},
+		{name: "error", version: "1.4", wantErr: true},
+		{name: "empty", version: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Version(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Version() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Version() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCipherSuites(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []uint16
+		wantErr bool
+	}{
+		{name: "1", names: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, want: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}},
+		{name: "insecure", names: []string{"TLS_RSA_WITH_RC4_128_SHA"}, wantErr: true},
+		{name: "error", names: []string{"TLS_UNKNOWN"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CipherSuites(tt.names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CipherSuites() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CipherSuites() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfile(t *testing.T) {
+	suites, curves, err := Profile(ProfileFIPS)
+	if err != nil {
+		t.Fatalf("Profile() error = %v", err)
+	}
+	if _, err := CipherSuites(suites); err != nil {
+		t.Errorf("CipherSuites() of FIPS profile error = %v", err)
+	}
+	if got, err := CurvePreferences(curves); err != nil || !reflect.DeepEqual(got, []tls.CurveID{tls.CurveP256, tls.CurveP384}) {
+		t.Errorf("CurvePreferences() of FIPS profile = %v, %v", got, err)
+	}
+	if _, _, err := Profile("Modern"); err == nil {
+		t.Errorf("Profile() expects error for unknown profile")
+	}
+}
@@ -0,0 +1,157 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/k-cloud-labs/pkg/utils/interrupter"
+	"github.com/k-cloud-labs/pkg/utils/overridemanager"
+	"github.com/k-cloud-labs/pkg/utils/validatemanager"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/policyorder"
+)
+
+type fakeInterrupter struct{}
+
+func (fakeInterrupter) OnMutating(_, _ *unstructured.Unstructured, _ admissionv1.Operation) ([]jsonpatch.JsonPatchOperation, error) {
+	return nil, nil
+}
+
+func (fakeInterrupter) OnValidating(_, _ *unstructured.Unstructured, _ admissionv1.Operation) error {
+	return nil
+}
+
+func (fakeInterrupter) OnStartUp() error { return nil }
+
+func (fakeInterrupter) AddInterrupter(schema.GroupVersionKind, interrupter.PolicyInterrupter) {}
+
+// labelOverrideManager sets the label team=infra.
+type labelOverrideManager struct{}
+
+func (labelOverrideManager) ApplyOverridePolicies(_ context.Context, rawObj, _ *unstructured.Unstructured,
+	_ admissionv1.Operation) (*overridemanager.AppliedOverrides, *overridemanager.AppliedOverrides, error) {
+	rawObj.SetLabels(map[string]string{"team": "infra"})
+	return nil, nil, nil
+}
+
+type allowValidateManager struct{}
+
+func (allowValidateManager) ApplyValidatePolicies(_ context.Context, _, _ *unstructured.Unstructured,
+	_ admissionv1.Operation) (*validatemanager.ValidateResult, error) {
+	return &validatemanager.ValidateResult{Valid: true}, nil
+}
+
+func newTestWebhook(t *testing.T, handler admission.Handler) *webhook.Admission {
+	wh := &webhook.Admission{Handler: handler}
+	if err := wh.InjectLogger(logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	if err := wh.InjectScheme(runtime.NewScheme()); err != nil {
+		t.Fatal(err)
+	}
+	return wh
+}
+
+// review is the part of AdmissionReview shared by v1 and v1beta1.
+type review struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Response   struct {
+		UID       string `json:"uid"`
+		Allowed   bool   `json:"allowed"`
+		PatchType string `json:"patchType"`
+		Patch     []byte `json:"patch"`
+	} `json:"response"`
+}
+
+func serveReview(t *testing.T, wh *webhook.Admission, body string) review {
+	req := httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	wh.ServeHTTP(w, req)
+
+	var ret review
+	if err := json.Unmarshal(w.Body.Bytes(), &ret); err != nil {
+		t.Fatalf("failed to decode response %s: %v", w.Body.String(), err)
+	}
+	return ret
+}
+
+func TestAdmissionReviewVersions(t *testing.T) {
+	const pod = `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"nginx","namespace":"default","creationTimestamp":null}}`
+	mutating := newTestWebhook(t, NewMutatingAdmissionHandler(func(admission.Request) ([]OrderedOverrideManager, error) {
+		return []OrderedOverrideManager{{OverrideManager: labelOverrideManager{}, Policy: policyorder.Policy{Cluster: true, Name: "team"}}}, nil
+	}, nil, MutatingOptions{}, fakeInterrupter{}, nil))
+	validating := newTestWebhook(t, NewValidatingAdmissionHandler(func(admission.Request) validatemanager.ValidateManager {
+		return allowValidateManager{}
+	}, nil, fakeInterrupter{}, nil))
+
+	tests := []struct {
+		name        string
+		webhook     *webhook.Admission
+		body        string
+		wantVersion string
+		wantPatch   []jsonpatch.JsonPatchOperation
+	}{
+		{
+			name:        "v1",
+			wantVersion: "admission.k8s.io/v1",
+			webhook:     mutating,
+			body: `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"1","kind":{"version":"v1","kind":"Pod"},` +
+				`"resource":{"version":"v1","resource":"pods"},"namespace":"default","name":"nginx","operation":"CREATE","object":` + pod + `}}`,
+			wantPatch: []jsonpatch.JsonPatchOperation{{Operation: "add", Path: "/metadata/labels", Value: map[string]interface{}{"team": "infra"}}},
+		},
+		{
+			name:        "v1beta1",
+			wantVersion: "admission.k8s.io/v1beta1",
+			webhook:     mutating,
+			body: `{"apiVersion":"admission.k8s.io/v1beta1","kind":"AdmissionReview","request":{"uid":"1","kind":{"version":"v1","kind":"Pod"},` +
+				`"resource":{"version":"v1","resource":"pods"},"namespace":"default","name":"nginx","operation":"CREATE","object":` + pod + `}}`,
+			wantPatch: []jsonpatch.JsonPatchOperation{{Operation: "add", Path: "/metadata/labels", Value: map[string]interface{}{"team": "infra"}}},
+		},
+		{
+			// apiserver before 1.15 does not send oldObject of DELETE requests
+			name:        "v1beta1 delete",
+			wantVersion: "admission.k8s.io/v1beta1",
+			webhook:     validating,
+			body: `{"apiVersion":"admission.k8s.io/v1beta1","kind":"AdmissionReview","request":{"uid":"1","kind":{"version":"v1","kind":"Pod"},` +
+				`"resource":{"version":"v1","resource":"pods"},"namespace":"default","name":"nginx","operation":"DELETE"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := serveReview(t, tt.webhook, tt.body)
+			if got.APIVersion != tt.wantVersion {
+				t.Errorf("apiVersion = %v, want %v", got.APIVersion, tt.wantVersion)
+			}
+			if got.Kind != "AdmissionReview" || got.Response.UID != "1" || !got.Response.Allowed {
+				t.Fatalf("unexpected response %+v", got)
+			}
+
+			var patch []jsonpatch.JsonPatchOperation
+			if len(got.Response.Patch) != 0 {
+				if err := json.Unmarshal(got.Response.Patch, &patch); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(patch, tt.wantPatch) {
+				t.Errorf("patch = %v, want %v", patch, tt.wantPatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/controller/deletehook"
+	"github.com/k-cloud-labs/kinitiras/pkg/lister"
+)
+
+// DeleteHookFunc schedules delete hooks of an object being deleted, e.g. Enqueue of the delete hook controller.
+type DeleteHookFunc func(hook deletehook.Hook, metadata *deletehook.Metadata)
+
+// CheckDeleteHooks implements deletehook.CheckFunc, it applies the delete hook policies selecting
+// a DELETE request of obj. User selectors of the policies are matched against the user recorded in
+// deletehook.DeletedByAnnotation of obj, or an anonymous user if it is not recorded.
+func (m *PolicyManagers) CheckDeleteHooks(ctx context.Context, hook deletehook.Hook, obj *unstructured.Unstructured) (string, error) {
+	user, ok, err := deletehook.DeletedByFromAnnotations(obj.GetAnnotations())
+	if err != nil {
+		klog.ErrorS(err, "match delete hook policies against an anonymous user.", "resource", hook.Resource, "object", klog.KObj(obj))
+	} else if !ok {
+		klog.V(2).InfoS("user deleting the object is not recorded, match delete hook policies against an anonymous user.", "resource", hook.Resource, "object", klog.KObj(obj))
+	}
+
+	gvk := obj.GroupVersionKind()
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+		Resource:  metav1.GroupVersionResource{Group: hook.Resource.Group, Version: hook.Resource.Version, Resource: hook.Resource.Resource},
+		Namespace: hook.Namespace,
+		Name:      hook.Name,
+		Operation: admissionv1.Delete,
+		UserInfo:  user,
+	}}
+
+	managers, err := m.DeleteHookManagers(req)
+	if err != nil {
+		return "", err
+	}
+	violations, err := VerifyMutation(ctx, managers, obj, nil, admissionv1.Delete)
+	if err != nil || len(violations) == 0 {
+		return "", err
+	}
+
+	reasons := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", violation.Policy, violation.Reason))
+	}
+	return strings.Join(reasons, "; "), nil
+}
+
+// DeleteHookResources returns a deletehook.ResourcesFunc which returns the resources selected by delete hook
+// policies. Selectors of kinds unknown to mapper are skipped.
+func (m *PolicyManagers) DeleteHookResources(mapper meta.RESTMapper) deletehook.ResourcesFunc {
+	return func() ([]schema.GroupVersionResource, error) {
+		policies, err := lister.NewFilteredClusterValidatePolicyLister(m.ClusterValidatePolicyLister, deletehook.IsHookPolicy).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+
+		resources := make(map[schema.GroupVersionResource]struct{})
+		for _, policy := range policies {
+			for _, selector := range policy.Spec.ResourceSelectors {
+				gvk := schema.FromAPIVersionAndKind(selector.APIVersion, selector.Kind)
+				mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+				if err != nil {
+					klog.V(2).InfoS("skip unknown resource of delete hook policy.", "policy", policy.Name, "kind", gvk, "err", err)
+					continue
+				}
+				resources[mapping.Resource] = struct{}{}
+			}
+		}
+
+		ret := make([]schema.GroupVersionResource, 0, len(resources))
+		for gvr := range resources {
+			ret = append(ret, gvr)
+		}
+		sort.Slice(ret, func(i, j int) bool {
+			return ret[i].String() < ret[j].String()
+		})
+		return ret, nil
+	}
+}
+
+// deleteHook returns the hook of the object of req.
+func deleteHook(req admission.Request) deletehook.Hook {
+	return deletehook.Hook{
+		Resource:  schema.GroupVersionResource{Group: req.Resource.Group, Version: req.Resource.Version, Resource: req.Resource.Resource},
+		Namespace: req.Namespace,
+		Name:      req.Name,
+	}
+}
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"context"
+
+	"gomodules.xyz/jsonpatch/v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/policyorder"
+)
+
+// Evaluation is the result of applying the policies selecting a request to its object.
+type Evaluation struct {
+	// Applied are the override policies applied, in order.
+	Applied []string `json:"applied"`
+	// Conflicts are the paths written by more than one override policy.
+	Conflicts []policyorder.Conflict `json:"conflicts,omitempty"`
+	// NonIdempotent are the override policies changing the object again when applied twice.
+	NonIdempotent []string `json:"nonIdempotent,omitempty"`
+	// Object is the object mutated by override policies.
+	Object *unstructured.Unstructured `json:"object"`
+	// Patch is the patch the mutating webhook responds with.
+	Patch []jsonpatch.JsonPatchOperation `json:"patch,omitempty"`
+	// Violations are the validate policies rejecting the mutated object.
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// Evaluate applies override policies selecting req to obj, then validate policies selecting req to the mutated
+// object, without changing obj. Idempotency of override policies is checked if checkIdempotency is true.
+func (m *PolicyManagers) Evaluate(ctx context.Context, req admission.Request, obj, oldObj *unstructured.Unstructured,
+	checkIdempotency bool) (*Evaluation, error) {
+	overrideManagers, err := m.OverrideManagers(req)
+	if err != nil {
+		return nil, err
+	}
+
+	newObj := obj.DeepCopy()
+	result, err := ApplyOverridePolicies(ctx, overrideManagers, newObj, oldObj, req.Operation)
+	if err != nil {
+		return nil, err
+	}
+	evaluation := &Evaluation{Applied: result.Applied, Conflicts: result.Conflicts, Object: newObj}
+
+	if checkIdempotency {
+		if evaluation.NonIdempotent, err = CheckIdempotency(ctx, overrideManagers, newObj, oldObj, req.Operation); err != nil {
+			return nil, err
+		}
+	}
+	if evaluation.Patch, err = createPatch(obj, newObj, result.Patches); err != nil {
+		return nil, err
+	}
+
+	validateManagers, err := m.ValidateManagers(req)
+	if err != nil {
+		return nil, err
+	}
+	if evaluation.Violations, err = VerifyMutation(ctx, validateManagers, newObj.DeepCopy(), oldObj, req.Operation); err != nil {
+		return nil, err
+	}
+
+	return evaluation, nil
+}
@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// AppliedGenerationAnnotation is the annotation stamped on mutated objects. It is a hash of the policies selecting
+// the object and their generations in the order applied, and the UID of the admission request. A reinvocation for
+// an object stamped in the same request by the same policies is a no-op, even if other webhooks changed the object
+// since. The UID is generated by apiserver for each request, so a stamp sent by the client, on CREATE or copied
+// from a stored object, never matches.
+const AppliedGenerationAnnotation = "kinitiras.kcloudlabs.io/applied-generation"
+
+// appliedGeneration returns the value of AppliedGenerationAnnotation for an object mutated by managers in the
+// request uid. Reinvocations of a request have the same uid.
+func appliedGeneration(managers []OrderedOverrideManager, uid types.UID) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "uid=%s\n", uid)
+	for _, manager := range managers {
+		fmt.Fprintf(hash, "%s@%d\n", manager.Policy, manager.Policy.Generation)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// CheckIdempotency applies the policy of each manager once more to obj which has been mutated by managers,
+// and returns the policies changing it again. Such policies may duplicate entries when the webhook is reinvoked.
+func CheckIdempotency(ctx context.Context, managers []OrderedOverrideManager, obj, oldObj *unstructured.Unstructured,
+	operation admissionv1.Operation) ([]string, error) {
+	var policies []string
+	for _, manager := range managers {
+		again := obj.DeepCopy()
+		if _, _, err := manager.ApplyOverridePolicies(ctx, again, oldObj, operation); err != nil {
+			return nil, fmt.Errorf("failed to apply %s: %w", manager.Policy, err)
+		}
+		if len(changedPaths(obj, again)) != 0 {
+			policies = append(policies, manager.Policy.String())
+		}
+	}
+
+	return policies, nil
+}
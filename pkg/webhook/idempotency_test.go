@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/k-cloud-labs/pkg/utils/overridemanager"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/policyorder"
+)
+
+// sidecarOverrideManager appends a sidecar container, which is not idempotent.
+type sidecarOverrideManager struct{}
+
+func (sidecarOverrideManager) ApplyOverridePolicies(_ context.Context, rawObj, _ *unstructured.Unstructured,
+	_ admissionv1.Operation) (*overridemanager.AppliedOverrides, *overridemanager.AppliedOverrides, error) {
+	containers, _, _ := unstructured.NestedSlice(rawObj.Object, "spec", "containers")
+	containers = append(containers, map[string]interface{}{"name": "sidecar", "image": "sidecar"})
+	return nil, nil, unstructured.SetNestedSlice(rawObj.Object, containers, "spec", "containers")
+}
+
+func TestAppliedGeneration_Reinvocation(t *testing.T) {
+	mutating := newTestWebhook(t, NewMutatingAdmissionHandler(func(admission.Request) ([]OrderedOverrideManager, error) {
+		return []OrderedOverrideManager{{
+			OverrideManager: sidecarOverrideManager{},
+			Policy:          policyorder.Policy{Cluster: true, Name: "sidecar", Generation: 1},
+		}}, nil
+	}, nil, MutatingOptions{StampAppliedGeneration: true}, fakeInterrupter{}, nil))
+
+	// mutate applies the patch of the webhook to pod, the same as apiserver
+	mutate := func(uid string, operation admissionv1.Operation, pod, oldPod string) (string, int) {
+		request := fmt.Sprintf(`{"uid":%q,"kind":{"version":"v1","kind":"Pod"},"resource":{"version":"v1","resource":"pods"},`+
+			`"namespace":"default","name":"nginx","operation":%q,"object":%s`, uid, operation, pod)
+		if oldPod != "" {
+			request += `,"oldObject":` + oldPod
+		}
+		resp := serveReview(t, mutating, `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":`+request+`}}`)
+		if !resp.Response.Allowed {
+			t.Fatalf("request of %s is not allowed", pod)
+		}
+		if len(resp.Response.Patch) == 0 {
+			return pod, 0
+		}
+
+		var ops []interface{}
+		if err := json.Unmarshal(resp.Response.Patch, &ops); err != nil {
+			t.Fatal(err)
+		}
+		patch, err := jsonpatch.DecodePatch(resp.Response.Patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patched, err := patch.Apply([]byte(pod))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(patched), len(ops)
+	}
+	containers := func(pod string) int {
+		obj := &unstructured.Unstructured{}
+		if err := json.Unmarshal([]byte(pod), &obj.Object); err != nil {
+			t.Fatal(err)
+		}
+		items, _, _ := unstructured.NestedSlice(obj.Object, "spec", "containers")
+		return len(items)
+	}
+
+	const pod = `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"nginx","namespace":"default"},"spec":{"containers":[{"name":"nginx","image":"nginx"}]}}`
+	mutated, ops := mutate("1", admissionv1.Create, pod, "")
+	if ops == 0 || containers(mutated) != 2 {
+		t.Fatalf("first invocation got %d patches and %s, want the sidecar added", ops, mutated)
+	}
+
+	// another webhook changes the object, and this webhook is reinvoked
+	changed := new(unstructured.Unstructured)
+	if err := json.Unmarshal([]byte(mutated), &changed.Object); err != nil {
+		t.Fatal(err)
+	}
+	changed.SetLabels(map[string]string{"injected-by": "other"})
+	data, err := json.Marshal(changed.Object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reinvoked, ops := mutate("1", admissionv1.Create, string(data), ""); ops != 0 || containers(reinvoked) != 2 {
+		t.Errorf("reinvocation got %d patches and %s, want no patch", ops, reinvoked)
+	}
+
+	// another CREATE request copies the stamp, policies are applied again
+	if copied, _ := mutate("2", admissionv1.Create, string(data), ""); containers(copied) != 3 {
+		t.Errorf("create with a copied stamp got %s, want the sidecar added", copied)
+	}
+
+	// the next update carries the stamp of the stored object, policies are applied again
+	changed.SetResourceVersion("1")
+	stored, err := json.Marshal(changed.Object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unstructured.SetNestedSlice(changed.Object, []interface{}{map[string]interface{}{"name": "nginx", "image": "nginx:1.23"}}, "spec", "containers"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = json.Marshal(changed.Object); err != nil {
+		t.Fatal(err)
+	}
+	if updated, _ := mutate("3", admissionv1.Update, string(data), string(stored)); containers(updated) != 2 {
+		t.Errorf("update got %s, want the sidecar added", updated)
+	}
+}
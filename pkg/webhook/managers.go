@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"sort"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	policyv1alpha1 "github.com/k-cloud-labs/pkg/apis/policy/v1alpha1"
+	"github.com/k-cloud-labs/pkg/client/listers/policy/v1alpha1"
+	"github.com/k-cloud-labs/pkg/utils/dynamiclister"
+	"github.com/k-cloud-labs/pkg/utils/overridemanager"
+	"github.com/k-cloud-labs/pkg/utils/validatemanager"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/controller/deletehook"
+	"github.com/k-cloud-labs/kinitiras/pkg/lister"
+	"github.com/k-cloud-labs/kinitiras/pkg/policyfilter"
+	"github.com/k-cloud-labs/kinitiras/pkg/policyorder"
+)
+
+// NamedValidateManager is a ValidateManager which applies the single ClusterValidatePolicy Name.
+type NamedValidateManager struct {
+	validatemanager.ValidateManager
+	Name string
+}
+
+// ValidateManagersFunc returns a ValidateManager for each validate policy selecting the request.
+type ValidateManagersFunc func(req admission.Request) ([]NamedValidateManager, error)
+
+// PolicyManagers builds the managers applying policies which select a request.
+type PolicyManagers struct {
+	DynamicLister               dynamiclister.DynamicResourceLister
+	OverridePolicyLister        v1alpha1.OverridePolicyLister
+	ClusterOverridePolicyLister v1alpha1.ClusterOverridePolicyLister
+	ClusterValidatePolicyLister v1alpha1.ClusterValidatePolicyLister
+	// NamespaceLabels is used to evaluate namespace selectors of policies.
+	NamespaceLabels policyfilter.NamespaceLabelsFunc
+	// DeleteHooks enables delete hook policies, which hold the finalizer of objects being deleted
+	// instead of rejecting DELETE requests. They are ordinary validate policies if it is false.
+	DeleteHooks bool
+}
+
+// OverrideManagers returns an OverrideManager for each override policy selecting req,
+// in the order the policies are applied. Policies are listed once, and each manager only lists its own policy.
+// CUE programs of the policies are given the user of req.
+func (m *PolicyManagers) OverrideManagers(req admission.Request) ([]OrderedOverrideManager, error) {
+	filter := policyfilter.New(req, m.NamespaceLabels)
+	cops, err := lister.NewFilteredClusterOverridePolicyLister(m.ClusterOverridePolicyLister, filter).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []*policyv1alpha1.OverridePolicy
+	if req.Namespace != "" {
+		ops, err = lister.NewFilteredOverridePolicyLister(m.OverridePolicyLister, filter).OverridePolicies(req.Namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	managers := make([]OrderedOverrideManager, 0, len(cops)+len(ops))
+	for _, cop := range cops {
+		manager := overridemanager.NewOverrideManager(m.DynamicLister, lister.NewSliceClusterOverridePolicyLister(cop), lister.NewSliceOverridePolicyLister())
+		managers = append(managers, OrderedOverrideManager{
+			OverrideManager: userInfoOverrideManager{OverrideManager: manager, userInfo: req.UserInfo},
+			Policy:          policyorder.Policy{Cluster: true, Name: cop.Name, Priority: policyPriority(cop), Generation: cop.Generation},
+			Dependents:      policyDependents(cop),
+		})
+	}
+	for _, op := range ops {
+		manager := overridemanager.NewOverrideManager(m.DynamicLister, lister.NewSliceClusterOverridePolicyLister(), lister.NewSliceOverridePolicyLister(op))
+		managers = append(managers, OrderedOverrideManager{
+			OverrideManager: userInfoOverrideManager{OverrideManager: manager, userInfo: req.UserInfo},
+			Policy:          policyorder.Policy{Namespace: op.Namespace, Name: op.Name, Priority: policyPriority(op), Generation: op.Generation},
+			Dependents:      policyDependents(op),
+		})
+	}
+	sort.SliceStable(managers, func(i, j int) bool {
+		return policyorder.Less(managers[i].Policy, managers[j].Policy)
+	})
+
+	return managers, nil
+}
+
+// ValidateManager returns the ValidateManager which applies validate policies selecting req, their CUE programs
+// are given the user of req.
+func (m *PolicyManagers) ValidateManager(req admission.Request) validatemanager.ValidateManager {
+	return userInfoValidateManager{ValidateManager: validatemanager.NewValidateManager(m.DynamicLister,
+		lister.NewFilteredClusterValidatePolicyLister(m.ClusterValidatePolicyLister, m.validatePolicyFilter(req))), userInfo: req.UserInfo}
+}
+
+// ValidateManagers returns a ValidateManager for each validate policy selecting req, sorted by name.
+func (m *PolicyManagers) ValidateManagers(req admission.Request) ([]NamedValidateManager, error) {
+	return m.namedValidateManagers(req, m.validatePolicyFilter(req))
+}
+
+// DeleteHookManagers returns a ValidateManager for each delete hook policy selecting req, sorted by name.
+func (m *PolicyManagers) DeleteHookManagers(req admission.Request) ([]NamedValidateManager, error) {
+	filter := policyfilter.New(req, m.NamespaceLabels)
+	return m.namedValidateManagers(req, func(policy metav1.Object) bool {
+		return deletehook.IsHookPolicy(policy) && filter(policy)
+	})
+}
+
+// validatePolicyFilter returns the filter of validate policies selecting req. Delete hook policies do not
+// select DELETE requests when delete hooks are enabled, they are applied by the delete hook controller.
+func (m *PolicyManagers) validatePolicyFilter(req admission.Request) lister.PolicyFilter {
+	filter := policyfilter.New(req, m.NamespaceLabels)
+	if !m.DeleteHooks || req.Operation != admissionv1.Delete {
+		return filter
+	}
+
+	return func(policy metav1.Object) bool {
+		return !deletehook.IsHookPolicy(policy) && filter(policy)
+	}
+}
+
+// namedValidateManagers returns a ValidateManager for each validate policy passing filter, their CUE programs are
+// given the user of req. Policies are listed once, and each manager only lists its own policy.
+func (m *PolicyManagers) namedValidateManagers(req admission.Request, filter lister.PolicyFilter) ([]NamedValidateManager, error) {
+	cvps, err := lister.NewFilteredClusterValidatePolicyLister(m.ClusterValidatePolicyLister, filter).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	managers := make([]NamedValidateManager, 0, len(cvps))
+	for _, cvp := range cvps {
+		manager := validatemanager.NewValidateManager(m.DynamicLister, lister.NewSliceClusterValidatePolicyLister(cvp))
+		managers = append(managers, NamedValidateManager{
+			ValidateManager: userInfoValidateManager{ValidateManager: manager, userInfo: req.UserInfo},
+			Name:            cvp.Name,
+		})
+	}
+	sort.Slice(managers, func(i, j int) bool {
+		return managers[i].Name < managers[j].Name
+	})
+
+	return managers, nil
+}
+
+func policyDependents(policy metav1.Object) []schema.GroupResource {
+	dependents, err := deletehook.DependentsFromAnnotations(policy.GetAnnotations())
+	if err != nil {
+		klog.ErrorS(err, "skip dependents of policy.", "policy", klog.KObj(policy))
+	}
+	return dependents
+}
+
+func policyPriority(policy metav1.Object) int32 {
+	priority, err := policyorder.PriorityFromAnnotations(policy.GetAnnotations())
+	if err != nil {
+		klog.ErrorS(err, "use default priority.", "policy", klog.KObj(policy))
+	}
+	return priority
+}
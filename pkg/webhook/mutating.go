@@ -6,10 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
+	"gomodules.xyz/jsonpatch/v2"
 	admissionv1 "k8s.io/api/admission/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/klog/v2"
 	utiltrace "k8s.io/utils/trace"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -18,12 +21,47 @@ import (
 	"github.com/k-cloud-labs/pkg/utils"
 	"github.com/k-cloud-labs/pkg/utils/interrupter"
 	"github.com/k-cloud-labs/pkg/utils/overridemanager"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/allowlist"
+	"github.com/k-cloud-labs/kinitiras/pkg/controller/deletehook"
+	"github.com/k-cloud-labs/kinitiras/pkg/policyorder"
+	"github.com/k-cloud-labs/kinitiras/pkg/util/patch"
 )
 
+// OrderedOverrideManager is an OverrideManager which applies the single policy Policy.
+type OrderedOverrideManager struct {
+	overridemanager.OverrideManager
+	Policy policyorder.Policy
+	// Dependents are the resources of dependents which changes of labels and annotations made by the policy
+	// on DELETE are applied to, from deletehook.DependentsAnnotation of the policy.
+	Dependents []schema.GroupResource
+}
+
+// OverrideManagerFunc returns OverrideManagers which apply policies selecting the request,
+// one for each policy in the order the policies are applied.
+type OverrideManagerFunc func(req admission.Request) ([]OrderedOverrideManager, error)
+
+// MutatingOptions contains optional behaviors of MutatingAdmission.
+type MutatingOptions struct {
+	// VerifyMode decides how to handle a mutated object which violates validate policies.
+	VerifyMode VerifyMode
+	// StampAppliedGeneration stamps AppliedGenerationAnnotation on mutated objects and skips reinvocations
+	// of the same request for objects mutated by the same policies.
+	StampAppliedGeneration bool
+	// CheckIdempotency applies policies twice and reports policies which are not idempotent.
+	CheckIdempotency bool
+	// DeleteHook schedules changes of labels and annotations made by policies on DELETE to be applied
+	// to the object being deleted and its dependents. The changes are discarded if it is nil.
+	DeleteHook DeleteHookFunc
+}
+
 type MutatingAdmission struct {
 	decoder                  *admission.Decoder
-	overrideManager          overridemanager.OverrideManager
+	overrideManager          OverrideManagerFunc
+	validateManagers         ValidateManagersFunc
+	opts                     MutatingOptions
 	policyInterrupterManager interrupter.PolicyInterrupter
+	allowlist                *allowlist.Allowlist
 }
 
 // Check if our MutatingAdmission implements necessary interface
@@ -34,6 +72,10 @@ func (a *MutatingAdmission) Handle(ctx context.Context, req admission.Request) a
 	trace := utiltrace.New("Mutating", traceFields(req, "mutating")...)
 	defer trace.LogIfLong(50 * time.Millisecond)
 
+	if a.allowlist.Match(req) {
+		return admission.Allowed("")
+	}
+
 	obj, oldObj, err := decodeObj(a.decoder, req)
 	if err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
@@ -58,13 +100,7 @@ func (a *MutatingAdmission) Handle(ctx context.Context, req admission.Request) a
 
 			klog.V(5).InfoS("policy patches.", "patches", buf.String())
 		}
-		// patch data
-		patchedObj, err := json.Marshal(newObj)
-		if err != nil {
-			return admission.Errored(http.StatusInternalServerError, err)
-		}
-
-		return admission.PatchResponseFromRaw(req.Object.Raw, patchedObj)
+		return patchResponse(req, obj, newObj, nil)
 	}
 
 	if klog.V(6).Enabled() {
@@ -78,37 +114,125 @@ func (a *MutatingAdmission) Handle(ctx context.Context, req admission.Request) a
 		klog.V(6).InfoS("override obj", "obj", buf.String())
 	}
 	if newObj.GetNamespace() == "" && req.Namespace != "" {
+		// namespace of the request is not a change of policies, keep it out of the patch
+		obj.SetNamespace(req.Namespace)
 		newObj.SetNamespace(req.Namespace)
 	}
-	cops, ops, err := a.overrideManager.ApplyOverridePolicies(utils.ContextWithTrace(ctx, trace), newObj, oldObj, req.Operation)
+	managers, err := a.overrideManager(req)
 	if err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
 
-	if klog.V(4).Enabled() {
-		var opBytes, copBytes []byte
-		if ops != nil {
-			opBytes, err = ops.MarshalJSON()
-			if err != nil {
+	stamp := a.opts.StampAppliedGeneration && len(managers) != 0 && req.UID != "" && req.Operation != admissionv1.Delete
+	if stamp && newObj.GetAnnotations()[AppliedGenerationAnnotation] == appliedGeneration(managers, req.UID) {
+		klog.V(4).InfoS("skip object mutated by the same policies.", "resource", klog.KObj(obj))
+		return admission.Allowed("")
+	}
+
+	ctx = utils.ContextWithTrace(ctx, trace)
+	result, err := ApplyOverridePolicies(ctx, managers, newObj, oldObj, req.Operation)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	klog.InfoS("override policy applied.", "resource", klog.KObj(obj), "order", result.Applied)
+	for _, conflict := range result.Conflicts {
+		klog.InfoS("override policies wrote the same path.", "resource", klog.KObj(obj), "path", conflict.Path, "policies", conflict.Policies)
+	}
+
+	var warnings []string
+	audit := auditAnnotations(result.Applied, result.Conflicts)
+	if a.opts.CheckIdempotency && len(result.Applied) != 0 {
+		policies, err := CheckIdempotency(ctx, managers, newObj, oldObj, req.Operation)
+		if err != nil {
+			klog.ErrorS(err, "failed to check idempotency of override policies.", "resource", klog.KObj(obj))
+		}
+		if len(policies) != 0 {
+			klog.InfoS("override policies are not idempotent.", "resource", klog.KObj(obj), "policies", policies)
+			warnings = append(warnings, "override policies are not idempotent: "+strings.Join(policies, ", "))
+			audit[nonIdempotentPoliciesAuditKey] = strings.Join(policies, ",")
+		}
+	}
+	if a.opts.VerifyMode != VerifyModeNone && len(result.Applied) != 0 && req.Operation != admissionv1.Delete {
+		msg, err := a.verifyMutation(ctx, req, newObj, oldObj, result.Applied)
+		if err != nil {
+			klog.ErrorS(err, "failed to verify mutated object.", "resource", klog.KObj(obj))
+			if a.opts.VerifyMode == VerifyModeDeny {
 				return admission.Errored(http.StatusInternalServerError, err)
 			}
 		}
-		if cops != nil {
-			copBytes, err = cops.MarshalJSON()
-			if err != nil {
-				return admission.Errored(http.StatusInternalServerError, err)
+		if msg != "" {
+			klog.InfoS("mutated object violates validate policies.", "resource", klog.KObj(obj), "message", msg)
+			if a.opts.VerifyMode == VerifyModeDeny {
+				resp := admission.Denied(msg)
+				resp.AuditAnnotations = audit
+				return resp
 			}
+			warnings = append(warnings, msg)
 		}
-		klog.V(4).InfoS("override policy applied.", "resource", klog.KObj(obj), utils.AppliedOverrides, string(opBytes), utils.AppliedClusterOverrides, string(copBytes))
-	} else {
-		klog.InfoS("override policy applied.", "resource", klog.KObj(obj))
 	}
 
+	if stamp && len(result.Applied) != 0 {
+		annotations := newObj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[AppliedGenerationAnnotation] = appliedGeneration(managers, req.UID)
+		newObj.SetAnnotations(annotations)
+	}
+
+	var resp admission.Response
 	if req.Operation == admissionv1.Delete {
-		return admission.Allowed("")
+		// objects being deleted can not be patched, the request is allowed as it is
+		if len(result.Applied) != 0 {
+			a.deleteHook(req, obj, newObj, result.Dependents)
+		}
+		resp = admission.Allowed("")
+	} else {
+		resp = patchResponse(req, obj, newObj, result.Patches)
 	}
+	resp.AuditAnnotations = audit
+	resp.Warnings = warnings
+	return resp
+}
 
-	patchedObj, err := json.Marshal(newObj)
+// deleteHook schedules changes of labels and annotations from obj to newObj to be applied to the object being
+// deleted, and dependents to its dependents, by the delete hook controller. Other changes are discarded.
+func (a *MutatingAdmission) deleteHook(req admission.Request, obj, newObj *unstructured.Unstructured, dependents map[schema.GroupResource]*deletehook.Metadata) {
+	metadata := deletehook.MetadataChanges(obj, newObj, isAppliedOverridesAnnotation)
+	metadata.Dependents = dependents
+	if a.opts.DeleteHook == nil || metadata.Empty() || (req.DryRun != nil && *req.DryRun) {
+		klog.V(4).InfoS("discard changes of override policies to the object being deleted.", "resource", klog.KObj(obj))
+		return
+	}
+
+	klog.V(4).InfoS("schedule labels and annotations of override policies for the object being deleted.", "resource", klog.KObj(obj))
+	a.opts.DeleteHook(deleteHook(req), metadata)
+}
+
+// patchResponse returns a response patching original to current. The patch contains the operations made by
+// override policies, patches, followed by the changes to current made after them, e.g. the applied generation
+// stamp. The changes are diffed from original if patches is nil or does not apply, and from the raw object
+// of req as the last resort.
+func patchResponse(req admission.Request, original, current *unstructured.Unstructured, patches []jsonpatch.JsonPatchOperation) admission.Response {
+	patches, err := createPatch(original, current, patches)
+	if err == nil {
+		klog.V(5).InfoS("create patch.", "resource", klog.KObj(current), "patchesCount", len(patches))
+		resp := admission.Response{
+			Patches: patches,
+			AdmissionResponse: admissionv1.AdmissionResponse{
+				Allowed: true,
+			},
+		}
+		if len(patches) != 0 {
+			pt := admissionv1.PatchTypeJSONPatch
+			resp.PatchType = &pt
+		}
+		return resp
+	}
+	klog.ErrorS(err, "failed to create patch, fall back to diff the raw object.", "resource", klog.KObj(current))
+
+	patchedObj, err := json.Marshal(current)
 	if err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
@@ -116,6 +240,41 @@ func (a *MutatingAdmission) Handle(ctx context.Context, req admission.Request) a
 	return admission.PatchResponseFromRaw(req.Object.Raw, patchedObj)
 }
 
+// createPatch returns patches followed by the operations turning original patched by them into current,
+// or the operations turning original into current if patches is nil or fails to apply.
+func createPatch(original, current *unstructured.Unstructured, patches []jsonpatch.JsonPatchOperation) ([]jsonpatch.JsonPatchOperation, error) {
+	if patches == nil {
+		return patch.CreatePatch(original.Object, current.Object)
+	}
+
+	patched, err := patch.Apply(original.Object, patches)
+	if err != nil {
+		klog.ErrorS(err, "failed to apply patches of override policies, fall back to diff the object.", "resource", klog.KObj(current))
+		return patch.CreatePatch(original.Object, current.Object)
+	}
+	rest, err := patch.CreatePatch(patched, current.Object)
+	if err != nil {
+		return nil, err
+	}
+	return append(patches[:len(patches):len(patches)], rest...), nil
+}
+
+// verifyMutation applies validate policies selecting req to the mutated object and returns
+// a message describing violations, or empty if there is none.
+func (a *MutatingAdmission) verifyMutation(ctx context.Context, req admission.Request, newObj, oldObj *unstructured.Unstructured, applied []string) (string, error) {
+	managers, err := a.validateManagers(req)
+	if err != nil {
+		return "", err
+	}
+
+	violations, err := VerifyMutation(ctx, managers, newObj.DeepCopy(), oldObj, req.Operation)
+	if err != nil || len(violations) == 0 {
+		return "", err
+	}
+
+	return ViolationMessage(applied, violations), nil
+}
+
 // InjectDecoder implements admission.DecoderInjector interface.
 // A decoder will be automatically injected.
 func (a *MutatingAdmission) InjectDecoder(d *admission.Decoder) error {
@@ -123,10 +282,17 @@ func (a *MutatingAdmission) InjectDecoder(d *admission.Decoder) error {
 	return nil
 }
 
-func NewMutatingAdmissionHandler(overrideManager overridemanager.OverrideManager, policyInterrupterManager interrupter.PolicyInterrupterManager) webhook.AdmissionHandler {
+func NewMutatingAdmissionHandler(overrideManager OverrideManagerFunc, validateManagers ValidateManagersFunc, opts MutatingOptions,
+	policyInterrupterManager interrupter.PolicyInterrupterManager, allowlist *allowlist.Allowlist) webhook.AdmissionHandler {
+	if opts.VerifyMode == "" {
+		opts.VerifyMode = VerifyModeNone
+	}
 	return &MutatingAdmission{
 		overrideManager:          overrideManager,
+		validateManagers:         validateManagers,
+		opts:                     opts,
 		policyInterrupterManager: policyInterrupterManager,
+		allowlist:                allowlist,
 	}
 }
 
@@ -155,14 +321,33 @@ func decodeObj(decoder *admission.Decoder, req admission.Request) (*unstructured
 	case admissionv1.Delete:
 		// In reference to PR: https://github.com/kubernetes/kubernetes/pull/76346
 		// OldObject contains the object being deleted
+		if len(req.OldObject.Raw) == 0 {
+			// apiserver before 1.15 sends v1beta1 reviews of DELETE requests without OldObject,
+			// only the identity of the object is known
+			klog.V(4).InfoS("DELETE request without oldObject.", "kind", req.Kind, "namespace", req.Namespace, "name", req.Name)
+			obj.SetNamespace(req.Namespace)
+			obj.SetName(req.Name)
+			break
+		}
 		err := decoder.DecodeRaw(req.OldObject, obj)
 		if err != nil {
 			return nil, nil, err
 		}
+	case admissionv1.Connect:
+		// Object contains the options of the connection, e.g. PodExecOptions for pods/exec
+		err := decoder.DecodeRaw(req.Object, obj)
+		if err != nil {
+			return nil, nil, err
+		}
 	default:
 		return nil, nil, errors.New("unsupported operation")
 	}
 
+	// options objects of CONNECT requests may be sent without type meta, so are objects built from DELETE requests
+	if obj.GetKind() == "" {
+		obj.SetGroupVersionKind(schema.GroupVersionKind{Group: req.Kind.Group, Version: req.Kind.Version, Kind: req.Kind.Kind})
+	}
+
 	return obj, oldObj, nil
 }
 
@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+
+	"github.com/k-cloud-labs/pkg/utils"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/controller/deletehook"
+	"github.com/k-cloud-labs/kinitiras/pkg/policyorder"
+	"github.com/k-cloud-labs/kinitiras/pkg/util/patch"
+)
+
+const (
+	// appliedOverridePoliciesAuditKey is the audit annotation listing override policies which changed the object,
+	// in the order they were applied.
+	appliedOverridePoliciesAuditKey = "applied-override-policies"
+	// overridePolicyConflictsAuditKey is the audit annotation listing paths written by more than one override policy.
+	overridePolicyConflictsAuditKey = "override-policy-conflicts"
+	// nonIdempotentPoliciesAuditKey is the audit annotation listing override policies changing the object again
+	// when they are applied twice.
+	nonIdempotentPoliciesAuditKey = "non-idempotent-override-policies"
+)
+
+// OverrideResult is the result of applying override policies to an object.
+type OverrideResult struct {
+	// Applied are the policies which changed the object, in the order they were applied.
+	Applied []string
+	// Conflicts are paths written by more than one policy.
+	Conflicts []policyorder.Conflict
+	// Patches are the json patch operations made by each policy, in the order applied. It is nil if the
+	// operations of a policy could not be created, the mutated object is diffed then.
+	Patches []jsonpatch.JsonPatchOperation
+	// Dependents are the changes of labels and annotations made on DELETE by policies with dependents,
+	// by the resource of dependents.
+	Dependents map[schema.GroupResource]*deletehook.Metadata
+}
+
+// ApplyOverridePolicies applies the policy of each manager to obj in order, and records which policies changed it
+// and the operations they made. Override managers change obj in place, so the operations of each policy are the
+// changes to the object as it was before the policy.
+func ApplyOverridePolicies(ctx context.Context, managers []OrderedOverrideManager, obj, oldObj *unstructured.Unstructured,
+	operation admissionv1.Operation) (*OverrideResult, error) {
+	detector := policyorder.NewConflictDetector()
+	applied := make([]string, 0, len(managers))
+	patches := make([]jsonpatch.JsonPatchOperation, 0)
+	var dependents map[schema.GroupResource]*deletehook.Metadata
+	overrides := newAppliedOverrides(obj)
+	for _, manager := range managers {
+		before := obj.DeepCopy()
+		overrides.reset(obj)
+		cops, ops, err := manager.ApplyOverridePolicies(ctx, obj, oldObj, operation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply %s: %w", manager.Policy, err)
+		}
+		overrides.merge(obj)
+
+		if klog.V(4).Enabled() {
+			var opBytes, copBytes []byte
+			if ops != nil {
+				if opBytes, err = ops.MarshalJSON(); err != nil {
+					return nil, err
+				}
+			}
+			if cops != nil {
+				if copBytes, err = cops.MarshalJSON(); err != nil {
+					return nil, err
+				}
+			}
+			klog.V(4).InfoS("override policy applied.", "resource", klog.KObj(obj), "policy", manager.Policy, utils.AppliedOverrides, string(opBytes), utils.AppliedClusterOverrides, string(copBytes))
+		}
+
+		if paths := changedPaths(before, obj); len(paths) != 0 {
+			applied = append(applied, manager.Policy.String())
+			detector.Add(manager.Policy.String(), paths)
+		}
+		if operation == admissionv1.Delete && len(manager.Dependents) != 0 {
+			changes := deletehook.MetadataChanges(before, obj, isAppliedOverridesAnnotation)
+			for _, resource := range manager.Dependents {
+				if dependents == nil {
+					dependents = map[schema.GroupResource]*deletehook.Metadata{}
+				}
+				dependents[resource] = dependents[resource].Merge(changes)
+			}
+		}
+		if patches != nil {
+			ops, err := patch.CreatePatch(before.Object, obj.Object)
+			if err != nil {
+				klog.ErrorS(err, "failed to create patch of override policy.", "resource", klog.KObj(obj), "policy", manager.Policy)
+				patches = nil
+				continue
+			}
+			patches = append(patches, ops...)
+		}
+	}
+
+	return &OverrideResult{Applied: applied, Conflicts: detector.Conflicts(), Patches: patches, Dependents: dependents}, nil
+}
+
+// changedPaths returns paths changed by an override policy, except annotations recording applied
+// overrides which are merged across policies.
+func changedPaths(before, after *unstructured.Unstructured) []string {
+	paths := policyorder.ChangedPaths(before.Object, after.Object)
+	ret := paths[:0]
+	for _, path := range paths {
+		if strings.HasPrefix(path, "/metadata/annotations/") && isAppliedOverridesAnnotation(path) {
+			continue
+		}
+		ret = append(ret, path)
+	}
+	return ret
+}
+
+// isAppliedOverridesAnnotation returns whether key is an annotation recording applied overrides.
+func isAppliedOverridesAnnotation(key string) bool {
+	return strings.HasSuffix(key, utils.AppliedOverrides) || strings.HasSuffix(key, utils.AppliedClusterOverrides)
+}
+
+// appliedOverrides merges the annotations recording applied overrides. Each manager overwrites them with the
+// overriders of its own policy, so they are merged to record the overriders of all policies in the order applied.
+type appliedOverrides struct {
+	// original are the annotations before policies were applied, kept if no policy records overrides.
+	original map[string]string
+	// items are the overriders recorded by policies applied so far, by annotation.
+	items map[string][]json.RawMessage
+}
+
+func newAppliedOverrides(obj *unstructured.Unstructured) *appliedOverrides {
+	original := map[string]string{}
+	for key, value := range obj.GetAnnotations() {
+		if isAppliedOverridesAnnotation(key) {
+			original[key] = value
+		}
+	}
+	return &appliedOverrides{original: original, items: map[string][]json.RawMessage{}}
+}
+
+// reset removes the annotations from obj, so the ones written by the next policy can be told apart.
+func (a *appliedOverrides) reset(obj *unstructured.Unstructured) {
+	for key := range obj.GetAnnotations() {
+		if isAppliedOverridesAnnotation(key) {
+			unstructured.RemoveNestedField(obj.Object, "metadata", "annotations", key)
+		}
+	}
+}
+
+// merge appends the overriders recorded by the last policy to the ones of earlier policies, and sets the merged
+// annotations to obj. An annotation which is not a json list is left as written by the last policy.
+func (a *appliedOverrides) merge(obj *unstructured.Unstructured) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		if !isAppliedOverridesAnnotation(key) {
+			continue
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal([]byte(value), &items); err != nil {
+			klog.ErrorS(err, "failed to merge applied overrides.", "annotation", key)
+			delete(a.items, key)
+			a.original[key] = value
+			continue
+		}
+		if len(items) != 0 {
+			a.items[key] = append(a.items[key], items...)
+		}
+	}
+
+	for key, value := range a.original {
+		annotations[key] = value
+	}
+	for key, items := range a.items {
+		data, err := json.Marshal(items)
+		if err != nil {
+			klog.ErrorS(err, "failed to merge applied overrides.", "annotation", key)
+			continue
+		}
+		annotations[key] = string(data)
+	}
+	if len(annotations) != 0 {
+		obj.SetAnnotations(annotations)
+	}
+}
+
+func auditAnnotations(applied []string, conflicts []policyorder.Conflict) map[string]string {
+	if len(applied) == 0 {
+		return map[string]string{}
+	}
+
+	annotations := map[string]string{appliedOverridePoliciesAuditKey: strings.Join(applied, ",")}
+	if len(conflicts) != 0 {
+		data, err := json.Marshal(conflicts)
+		if err != nil {
+			klog.ErrorS(err, "failed to encode override policy conflicts.")
+		} else {
+			annotations[overridePolicyConflictsAuditKey] = string(data)
+		}
+	}
+	return annotations
+}
@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/k-cloud-labs/pkg/utils"
+	"github.com/k-cloud-labs/pkg/utils/overridemanager"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/policyorder"
+)
+
+const testAppliedOverridesAnnotation = "policy.kcloudlabs.io/" + utils.AppliedClusterOverrides
+
+// recordingOverrideManager sets the label of its policy and records it in the applied overrides annotation,
+// overwriting the ones of other policies.
+type recordingOverrideManager struct {
+	name string
+}
+
+func (m recordingOverrideManager) ApplyOverridePolicies(_ context.Context, rawObj, _ *unstructured.Unstructured,
+	_ admissionv1.Operation) (*overridemanager.AppliedOverrides, *overridemanager.AppliedOverrides, error) {
+	labels := rawObj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[m.name] = "true"
+	rawObj.SetLabels(labels)
+
+	annotations := rawObj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[testAppliedOverridesAnnotation] = fmt.Sprintf(`[{"policyName":%q}]`, m.name)
+	rawObj.SetAnnotations(annotations)
+	return nil, nil, nil
+}
+
+func TestApplyOverridePolicies(t *testing.T) {
+	var managers []OrderedOverrideManager
+	for _, name := range []string{"a", "b"} {
+		managers = append(managers, OrderedOverrideManager{
+			OverrideManager: recordingOverrideManager{name: name},
+			Policy:          policyorder.Policy{Cluster: true, Name: name},
+		})
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetName("test")
+	obj.SetLabels(map[string]string{"app": "test"})
+	obj.SetAnnotations(map[string]string{testAppliedOverridesAnnotation: `[{"policyName":"previous"}]`})
+
+	result, err := ApplyOverridePolicies(context.Background(), managers, obj, nil, admissionv1.Update)
+	if err != nil {
+		t.Fatalf("ApplyOverridePolicies() error = %v", err)
+	}
+	if want := []string{"ClusterOverridePolicy/a", "ClusterOverridePolicy/b"}; !reflect.DeepEqual(result.Applied, want) {
+		t.Errorf("ApplyOverridePolicies() applied = %v, want %v", result.Applied, want)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Errorf("ApplyOverridePolicies() conflicts = %v, want none", result.Conflicts)
+	}
+	if got, want := obj.GetAnnotations()[testAppliedOverridesAnnotation], `[{"policyName":"a"},{"policyName":"b"}]`; got != want {
+		t.Errorf("ApplyOverridePolicies() annotation = %v, want %v", got, want)
+	}
+}
+
+// initContainerOverrideManager inserts an init container at the front.
+type initContainerOverrideManager struct{}
+
+func (initContainerOverrideManager) ApplyOverridePolicies(_ context.Context, rawObj, _ *unstructured.Unstructured,
+	_ admissionv1.Operation) (*overridemanager.AppliedOverrides, *overridemanager.AppliedOverrides, error) {
+	containers, _, _ := unstructured.NestedSlice(rawObj.Object, "spec", "initContainers")
+	containers = append([]interface{}{map[string]interface{}{"name": "init"}}, containers...)
+	return nil, nil, unstructured.SetNestedSlice(rawObj.Object, containers, "spec", "initContainers")
+}
+
+func TestApplyOverridePolicies_Patches(t *testing.T) {
+	managers := []OrderedOverrideManager{
+		{OverrideManager: initContainerOverrideManager{}, Policy: policyorder.Policy{Cluster: true, Name: "init"}},
+		{OverrideManager: recordingOverrideManager{name: "a"}, Policy: policyorder.Policy{Cluster: true, Name: "a"}},
+	}
+
+	original := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "test"},
+		"spec": map[string]interface{}{
+			"initContainers": []interface{}{
+				map[string]interface{}{"name": "first"},
+				map[string]interface{}{"name": "second"},
+			},
+		},
+	}}
+	obj := original.DeepCopy()
+	result, err := ApplyOverridePolicies(context.Background(), managers, obj, nil, admissionv1.Create)
+	if err != nil {
+		t.Fatalf("ApplyOverridePolicies() error = %v", err)
+	}
+	want := []string{
+		"add /spec/initContainers/0",
+		"add /metadata/annotations",
+		"add /metadata/labels",
+	}
+	var got []string
+	for _, op := range result.Patches {
+		got = append(got, op.Operation+" "+op.Path)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplyOverridePolicies() patches = %v, want %v", got, want)
+	}
+
+	// changes made after policies are appended
+	annotations := obj.GetAnnotations()
+	annotations[AppliedGenerationAnnotation] = "1"
+	obj.SetAnnotations(annotations)
+	patches, err := createPatch(original, obj, result.Patches)
+	if err != nil {
+		t.Fatalf("createPatch() error = %v", err)
+	}
+	last := patches[len(patches)-1]
+	if len(patches) != len(want)+1 || last.Path != "/metadata/annotations/kinitiras.kcloudlabs.io~1applied-generation" {
+		t.Errorf("createPatch() = %v, want the patches of policies followed by adding the stamp", patches)
+	}
+}
+
+func TestApplyOverridePolicies_Dependents(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	managers := []OrderedOverrideManager{
+		{OverrideManager: recordingOverrideManager{name: "a"}, Policy: policyorder.Policy{Cluster: true, Name: "a"}, Dependents: []schema.GroupResource{pods}},
+		{OverrideManager: recordingOverrideManager{name: "b"}, Policy: policyorder.Policy{Cluster: true, Name: "b"}},
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("apps/v1")
+	obj.SetKind("StatefulSet")
+	obj.SetName("db")
+	result, err := ApplyOverridePolicies(context.Background(), managers, obj, nil, admissionv1.Delete)
+	if err != nil {
+		t.Fatalf("ApplyOverridePolicies() error = %v", err)
+	}
+	if len(result.Dependents) != 1 || result.Dependents[pods] == nil {
+		t.Fatalf("ApplyOverridePolicies() dependents = %v, want changes of pods", result.Dependents)
+	}
+	if labels := result.Dependents[pods].Labels; len(labels) != 1 || labels["a"] == nil {
+		t.Errorf("ApplyOverridePolicies() labels of dependents = %v, want the label of policy a only", labels)
+	}
+}
@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"context"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/k-cloud-labs/pkg/utils/overridemanager"
+	"github.com/k-cloud-labs/pkg/utils/validatemanager"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/userinfo"
+)
+
+// userInfoOverrideManager gives CUE programs of override policies the user of the request by the object.
+type userInfoOverrideManager struct {
+	overridemanager.OverrideManager
+	userInfo authenticationv1.UserInfo
+}
+
+// ApplyOverridePolicies applies override policies to rawObj with the user of the request set in its metadata,
+// which is removed afterwards.
+func (m userInfoOverrideManager) ApplyOverridePolicies(ctx context.Context, rawObj, oldObj *unstructured.Unstructured,
+	operation admissionv1.Operation) (*overridemanager.AppliedOverrides, *overridemanager.AppliedOverrides, error) {
+	remove, err := userinfo.SetInObject(rawObj, m.userInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer remove()
+
+	return m.OverrideManager.ApplyOverridePolicies(ctx, rawObj, oldObj, operation)
+}
+
+// userInfoValidateManager gives CUE programs of validate policies the user of the request by the object.
+type userInfoValidateManager struct {
+	validatemanager.ValidateManager
+	userInfo authenticationv1.UserInfo
+}
+
+// ApplyValidatePolicies applies validate policies to rawObj with the user of the request set in its metadata,
+// which is removed afterwards.
+func (m userInfoValidateManager) ApplyValidatePolicies(ctx context.Context, rawObj, oldObj *unstructured.Unstructured,
+	operation admissionv1.Operation) (*validatemanager.ValidateResult, error) {
+	remove, err := userinfo.SetInObject(rawObj, m.userInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer remove()
+
+	return m.ValidateManager.ApplyValidatePolicies(ctx, rawObj, oldObj, operation)
+}
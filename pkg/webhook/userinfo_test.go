@@ -0,0 +1,40 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/k-cloud-labs/pkg/utils/overridemanager"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/userinfo"
+)
+
+// creatorOverrideManager labels objects with the user read from the object, as a CUE program would.
+type creatorOverrideManager struct{}
+
+func (creatorOverrideManager) ApplyOverridePolicies(_ context.Context, rawObj, _ *unstructured.Unstructured,
+	_ admissionv1.Operation) (*overridemanager.AppliedOverrides, *overridemanager.AppliedOverrides, error) {
+	username, _, _ := unstructured.NestedString(rawObj.Object, "metadata", userinfo.CueField, "username")
+	rawObj.SetLabels(map[string]string{"created-by": username})
+	return nil, nil, nil
+}
+
+func TestUserInfoOverrideManager(t *testing.T) {
+	manager := userInfoOverrideManager{OverrideManager: creatorOverrideManager{}, userInfo: authenticationv1.UserInfo{Username: "ci"}}
+	obj := &unstructured.Unstructured{}
+	obj.SetName("test")
+
+	if _, _, err := manager.ApplyOverridePolicies(context.Background(), obj, nil, admissionv1.Create); err != nil {
+		t.Fatal(err)
+	}
+	if got := obj.GetLabels()["created-by"]; got != "ci" {
+		t.Errorf("created-by = %q, want ci", got)
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "metadata", userinfo.CueField); found {
+		t.Errorf("userInfo left in the object")
+	}
+}
@@ -5,21 +5,32 @@ import (
 	"net/http"
 	"time"
 
+	admissionv1 "k8s.io/api/admission/v1"
 	utiltrace "k8s.io/utils/trace"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
+	policyv1alpha1 "github.com/k-cloud-labs/pkg/apis/policy/v1alpha1"
 	"github.com/k-cloud-labs/pkg/utils"
 	"github.com/k-cloud-labs/pkg/utils/interrupter"
 	"github.com/k-cloud-labs/pkg/utils/validatemanager"
 
 	pkgadmission "github.com/k-cloud-labs/kinitiras/pkg/admission"
+	"github.com/k-cloud-labs/kinitiras/pkg/allowlist"
+	"github.com/k-cloud-labs/kinitiras/pkg/controller/deletehook"
+	"github.com/k-cloud-labs/kinitiras/pkg/policyfilter"
+	"github.com/k-cloud-labs/kinitiras/pkg/policyorder"
 )
 
+// ValidateManagerFunc returns the ValidateManager which applies policies selecting the request.
+type ValidateManagerFunc func(req admission.Request) validatemanager.ValidateManager
+
 type ValidatingAdmission struct {
 	decoder                  *admission.Decoder
-	validateManager          validatemanager.ValidateManager
+	validateManager          ValidateManagerFunc
+	deleteHook               DeleteHookFunc
 	policyInterrupterManager interrupter.PolicyInterrupter
+	allowlist                *allowlist.Allowlist
 }
 
 // Check if our MutatingAdmission implements necessary interface
@@ -30,18 +41,35 @@ func (v *ValidatingAdmission) Handle(ctx context.Context, req admission.Request)
 	trace := utiltrace.New("Mutating", traceFields(req, "validating")...)
 	defer trace.LogIfLong(50 * time.Millisecond)
 
+	if v.allowlist.Match(req) {
+		return admission.Allowed("")
+	}
+
 	obj, oldObj, err := decodeObj(v.decoder, req)
 	if err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
+	// policies stored with invalid annotations before they were checked can still be deleted
+	if obj.GroupVersionKind().Group == policyv1alpha1.SchemeGroupVersion.Group && req.Operation != admissionv1.Delete {
+		if err := policyfilter.ValidateAnnotations(obj.GetAnnotations()); err != nil {
+			return admission.Denied(err.Error())
+		}
+		if _, err := policyorder.PriorityFromAnnotations(obj.GetAnnotations()); err != nil {
+			return admission.Denied(err.Error())
+		}
+		if _, err := deletehook.DependentsFromAnnotations(obj.GetAnnotations()); err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+
 	// if obj is known policy, then run policy interrupter
 	err = v.policyInterrupterManager.OnValidating(obj, oldObj, req.Operation)
 	if err != nil {
 		return admission.Denied(err.Error())
 	}
 
-	result, err := v.validateManager.ApplyValidatePolicies(utils.ContextWithTrace(ctx, trace), obj, oldObj, req.Operation)
+	result, err := v.validateManager(req).ApplyValidatePolicies(utils.ContextWithTrace(ctx, trace), obj, oldObj, req.Operation)
 	if err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
@@ -50,6 +78,19 @@ func (v *ValidatingAdmission) Handle(ctx context.Context, req admission.Request)
 		return pkgadmission.ResponseFailure(false, result.Reason)
 	}
 
+	// objects held by the finalizer are handed to the delete hook controller when they are deleted, recording
+	// the user deleting them, or updated while being deleted, e.g. after the webhook restarted
+	if v.deleteHook != nil && deletehook.HasFinalizer(obj) && !(req.DryRun != nil && *req.DryRun) &&
+		(req.Operation == admissionv1.Delete || obj.GetDeletionTimestamp() != nil) {
+		var metadata *deletehook.Metadata
+		if req.Operation == admissionv1.Delete {
+			if metadata, err = deletehook.DeletedBy(req.UserInfo); err != nil {
+				return admission.Errored(http.StatusInternalServerError, err)
+			}
+		}
+		v.deleteHook(deleteHook(req), metadata)
+	}
+
 	return admission.Allowed("")
 }
 
@@ -60,9 +101,12 @@ func (a *ValidatingAdmission) InjectDecoder(d *admission.Decoder) error {
 	return nil
 }
 
-func NewValidatingAdmissionHandler(validateManager validatemanager.ValidateManager, policyInterrupterManager interrupter.PolicyInterrupterManager) webhook.AdmissionHandler {
+func NewValidatingAdmissionHandler(validateManager ValidateManagerFunc, deleteHook DeleteHookFunc,
+	policyInterrupterManager interrupter.PolicyInterrupterManager, allowlist *allowlist.Allowlist) webhook.AdmissionHandler {
 	return &ValidatingAdmission{
 		validateManager:          validateManager,
+		deleteHook:               deleteHook,
 		policyInterrupterManager: policyInterrupterManager,
+		allowlist:                allowlist,
 	}
 }
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/k-cloud-labs/pkg/utils/validatemanager"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/policyorder"
+	"github.com/k-cloud-labs/kinitiras/pkg/userinfo"
+)
+
+func TestValidatingAdmission_PolicyAnnotations(t *testing.T) {
+	validating := newTestWebhook(t, NewValidatingAdmissionHandler(func(admission.Request) validatemanager.ValidateManager {
+		return allowValidateManager{}
+	}, nil, fakeInterrupter{}, nil))
+	policy := func(annotation, value string) string {
+		return `{"apiVersion":"policy.kcloudlabs.io/v1alpha1","kind":"ClusterOverridePolicy","metadata":{"name":"p",` +
+			`"annotations":{"` + annotation + `":"` + value + `"}}}`
+	}
+	request := func(operation, field, object string) string {
+		return `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"1",` +
+			`"kind":{"group":"policy.kcloudlabs.io","version":"v1alpha1","kind":"ClusterOverridePolicy"},` +
+			`"resource":{"group":"policy.kcloudlabs.io","version":"v1alpha1","resource":"clusteroverridepolicies"},` +
+			`"name":"p","operation":"` + operation + `","` + field + `":` + object + `}}`
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		wantAllowed bool
+	}{
+		{
+			name:        "1",
+			body:        request("CREATE", "object", policy(userinfo.SelectorAnnotation, `{\"match\":{\"users\":[\"ci\"]}}`)),
+			wantAllowed: true,
+		},
+		{
+			name: "invalid user selector",
+			body: request("CREATE", "object", policy(userinfo.SelectorAnnotation, "{")),
+		},
+		{
+			name:        "delete with invalid user selector",
+			body:        request("DELETE", "oldObject", policy(userinfo.SelectorAnnotation, "{")),
+			wantAllowed: true,
+		},
+		{
+			name: "invalid priority",
+			body: request("UPDATE", "object", policy(policyorder.PriorityAnnotation, "high")),
+		},
+		{
+			name:        "delete with invalid priority",
+			body:        request("DELETE", "oldObject", policy(policyorder.PriorityAnnotation, "high")),
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serveReview(t, validating, tt.body); got.Response.Allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v", got.Response.Allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// VerifyMode decides how the mutating webhook handles a mutated object which violates validate policies.
+type VerifyMode string
+
+const (
+	// VerifyModeNone does not verify mutated objects.
+	VerifyModeNone VerifyMode = "None"
+	// VerifyModeWarn returns a warning to the client if the mutated object violates validate policies.
+	VerifyModeWarn VerifyMode = "Warn"
+	// VerifyModeDeny rejects the request if the mutated object violates validate policies.
+	VerifyModeDeny VerifyMode = "Deny"
+)
+
+// Violation is a validate policy rejecting an object.
+type Violation struct {
+	Policy string `json:"policy"`
+	Reason string `json:"reason"`
+}
+
+// VerifyMutation applies the policy of each manager to the mutated obj and returns the policies rejecting it.
+func VerifyMutation(ctx context.Context, managers []NamedValidateManager, obj, oldObj *unstructured.Unstructured,
+	operation admissionv1.Operation) ([]Violation, error) {
+	var violations []Violation
+	for _, manager := range managers {
+		result, err := manager.ApplyValidatePolicies(ctx, obj, oldObj, operation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply ClusterValidatePolicy/%s: %w", manager.Name, err)
+		}
+		if result != nil && !result.Valid {
+			violations = append(violations, Violation{Policy: "ClusterValidatePolicy/" + manager.Name, Reason: result.Reason})
+		}
+	}
+
+	return violations, nil
+}
+
+// ViolationMessage describes violations of an object mutated by the applied override policies.
+func ViolationMessage(applied []string, violations []Violation) string {
+	items := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		items = append(items, fmt.Sprintf("%s: %s", violation.Policy, violation.Reason))
+	}
+
+	return fmt.Sprintf("object mutated by %s violates %s", strings.Join(applied, ", "), strings.Join(items, "; "))
+}
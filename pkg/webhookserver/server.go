@@ -0,0 +1,147 @@
+package webhookserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/k-cloud-labs/kinitiras/pkg/auth"
+	"github.com/k-cloud-labs/kinitiras/pkg/util/tlsconfig"
+)
+
+const (
+	defaultCertName = "tls.crt"
+	defaultKeyName  = "tls.key"
+)
+
+// Options contains settings of TLS and client certificate verification.
+type Options struct {
+	// TLS contains the minimum version, cipher suites and curves of the server.
+	TLS tlsconfig.Config
+	// ClientCAFile is the CA bundle verifying client certificates, e.g. the CA of the webhook client
+	// certificate of kube-apiserver. Client certificates are not verified if it is empty.
+	ClientCAFile string
+	// AllowedClientNames are the common names and DNS names allowed in client certificates, empty means
+	// any certificate verified by ClientCAFile.
+	AllowedClientNames []string
+	// ProtectedPaths are the paths requiring a verified client certificate, e.g. /mutate and /validate.
+	// Other paths, e.g. probes of kubelet, are served without.
+	ProtectedPaths []string
+}
+
+// Server serves the webhooks registered to the embedded webhook.Server, the same as it does, but with cipher
+// suites and curves configurable, and verifies client certificates of requests to the protected paths.
+// webhook.Server can only require client certificates for all requests, which kubelet probes do not have.
+type Server struct {
+	*webhook.Server
+	opts Options
+}
+
+var _ manager.Runnable = &Server{}
+
+// New builds a Server serving the webhooks of server. Add it to manager instead of server.
+func New(server *webhook.Server, opts Options) *Server {
+	if server.WebhookMux == nil {
+		server.WebhookMux = http.NewServeMux()
+	}
+	return &Server{Server: server, opts: opts}
+}
+
+// Start implements manager.Runnable, it blocks until ctx is done.
+func (s *Server) Start(ctx context.Context) error {
+	certName, keyName := s.CertName, s.KeyName
+	if certName == "" {
+		certName = defaultCertName
+	}
+	if keyName == "" {
+		keyName = defaultKeyName
+	}
+	watcher, err := certwatcher.New(filepath.Join(s.CertDir, certName), filepath.Join(s.CertDir, keyName))
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := watcher.Start(ctx); err != nil {
+			klog.ErrorS(err, "certificate watcher of webhook server exits.")
+		}
+	}()
+
+	cfg := &tls.Config{
+		NextProtos:     []string{"h2"},
+		GetCertificate: watcher.GetCertificate,
+	}
+	s.opts.TLS.Apply(cfg)
+	if s.opts.ClientCAFile != "" {
+		if cfg.ClientCAs, err = loadCertPool(s.opts.ClientCAFile); err != nil {
+			return err
+		}
+		// certificates are required by the filter of protected paths only
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	listener, err := tls.Listen("tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.Port)), cfg)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		if err := srv.Shutdown(context.Background()); err != nil {
+			klog.ErrorS(err, "failed to shutdown webhook server.")
+		}
+	}()
+
+	klog.InfoS("starting webhook server.", "address", listener.Addr().String(), "verifyClientCerts", s.opts.ClientCAFile != "")
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handler serves the protected paths through the client certificate filter, and others by WebhookMux directly.
+func (s *Server) handler() http.Handler {
+	if s.opts.ClientCAFile == "" {
+		return s.WebhookMux
+	}
+
+	protected := auth.ClientCertificate(s.opts.AllowedClientNames)(s.WebhookMux)
+	paths := make(map[string]struct{}, len(s.opts.ProtectedPaths))
+	for _, path := range s.opts.ProtectedPaths {
+		paths[path] = struct{}{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := paths[r.URL.Path]; ok {
+			protected.ServeHTTP(w, r)
+			return
+		}
+		s.WebhookMux.ServeHTTP(w, r)
+	})
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in client CA file %s", file)
+	}
+	return pool, nil
+}